@@ -0,0 +1,122 @@
+package appstore
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AppTransaction is the decoded payload of signed app transaction from StoreKit 2
+// see: https://developer.apple.com/documentation/storekit/apptransaction
+type AppTransaction struct {
+	ReceiptType                string `json:"receiptType"`
+	Environment                string `json:"environment"`
+	AppAppleID                 int64  `json:"appAppleId"`
+	BundleID                   string `json:"bundleId"`
+	ApplicationVersion         string `json:"applicationVersion"`
+	VersionExternalIdentifier  int64  `json:"versionExternalIdentifier"`
+	ReceiptCreationDate        int64  `json:"receiptCreationDate"`
+	OriginalPurchaseDate       int64  `json:"originalPurchaseDate"`
+	OriginalApplicationVersion string `json:"originalApplicationVersion"`
+	DeviceVerification         string `json:"deviceVerification"`
+	DeviceVerificationNonce    string `json:"deviceVerificationNonce"`
+	PreorderDate               int64  `json:"preorderDate"`
+}
+
+// ParseSignedAppTransaction verifies and decodes signed app transaction.
+// roots is required to verify the certificate chain in the JWS header (see LoadRootCertificates).
+func ParseSignedAppTransaction(signed string, roots *x509.CertPool) (*AppTransaction, error) {
+	var tx AppTransaction
+	if err := parseSignedPayload(signed, roots, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetEnvironment returns the server environment of the app transaction
+func (tx *AppTransaction) GetEnvironment() string {
+	if tx.Environment != "" {
+		return tx.Environment
+	}
+	return tx.ReceiptType
+}
+
+// GetOriginalApplicationVersion returns the app version which the user purchased or downloaded at first
+func (tx *AppTransaction) GetOriginalApplicationVersion() string {
+	return tx.OriginalApplicationVersion
+}
+
+// GetOriginalPurchaseDate returns the date when the user purchased or downloaded the app at first
+func (tx *AppTransaction) GetOriginalPurchaseDate() time.Time {
+	return msToTime(tx.OriginalPurchaseDate)
+}
+
+// VerifyDevice checks the app transaction is issued for the device of the given identifierForVendor
+// see: https://developer.apple.com/documentation/storekit/apptransaction/3954436-deviceverification
+func (tx *AppTransaction) VerifyDevice(deviceID string) bool {
+	if tx.DeviceVerification == "" {
+		return false
+	}
+	hash := sha512.Sum384([]byte(strings.ToLower(tx.DeviceVerificationNonce) + strings.ToLower(deviceID)))
+	expected := base64.StdEncoding.EncodeToString(hash[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(tx.DeviceVerification)) == 1
+}
+
+// OriginalApplicationVersioner has the app version which the user purchased or downloaded at first.
+// Both of Receipt and AppTransaction implement this.
+type OriginalApplicationVersioner interface {
+	GetOriginalApplicationVersion() string
+}
+
+// IsGrandfathered checks the user purchased the app before cutoffVersion,
+// e.g. the first version when the paid app became free with in-app purchases.
+// Note that the original application version is the build number (CFBundleVersion) on iOS,
+// and it is always "1.0" in the sandbox environment.
+func IsGrandfathered(v OriginalApplicationVersioner, cutoffVersion string) bool {
+	original := v.GetOriginalApplicationVersion()
+	if original == "" {
+		return false
+	}
+	return compareVersion(original, cutoffVersion) < 0
+}
+
+// compareVersion compares dot separated version strings by each number,
+// and returns -1 if a < b, 0 if a == b and +1 if a > b.
+func compareVersion(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv string
+		if i < len(as) {
+			av = as[i]
+		}
+		if i < len(bs) {
+			bv = bs[i]
+		}
+
+		an, aErr := strconv.ParseInt(orZero(av), 10, 64)
+		bn, bErr := strconv.ParseInt(orZero(bv), 10, 64)
+		switch {
+		case aErr != nil || bErr != nil:
+			if c := strings.Compare(av, bv); c != 0 {
+				return c
+			}
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+	}
+	return 0
+}
+
+func orZero(v string) string {
+	if v == "" {
+		return "0"
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package appstore
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSignedAppTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	nonce := "8ab0a4d4-0000-4000-8000-000000000000"
+	deviceID := "E621E1F8-C36C-495A-93FC-0C247A3E6E5F"
+	hash := sha512.Sum384([]byte(nonce + "e621e1f8-c36c-495a-93fc-0c247a3e6e5f"))
+
+	signer := newTestSigner(t)
+	signed := signer.sign(t, map[string]interface{}{
+		"receiptType":                "Production",
+		"bundleId":                   "com.example.app",
+		"applicationVersion":         "200",
+		"originalApplicationVersion": "120",
+		"originalPurchaseDate":       1500000000000,
+		"deviceVerification":         base64.StdEncoding.EncodeToString(hash[:]),
+		"deviceVerificationNonce":    nonce,
+	})
+
+	tx, err := ParseSignedAppTransaction(signed, signer.roots)
+	assert.NoError(err)
+	assert.Equal("com.example.app", tx.BundleID)
+	assert.Equal("Production", tx.GetEnvironment())
+	assert.Equal("120", tx.GetOriginalApplicationVersion())
+	assert.Equal(time.Unix(1500000000, 0), tx.GetOriginalPurchaseDate())
+	assert.True(tx.VerifyDevice(deviceID))
+	assert.False(tx.VerifyDevice("00000000-0000-0000-0000-000000000000"))
+
+	tx.Environment = "Sandbox"
+	assert.Equal("Sandbox", tx.GetEnvironment())
+
+	_, err = ParseSignedAppTransaction("invalid", signer.roots)
+	assert.Equal(ErrInvalidJWS, err)
+}
+
+func TestIsGrandfathered(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		expected bool
+		original string
+		cutoff   string
+	}{
+		{true, "1.0", "2.0"},
+		{true, "1.9.9", "2.0"},
+		{true, "120", "200"},
+		{true, "9", "10"},
+		{false, "2.0", "2.0"},
+		{false, "2", "2.0.0"},
+		{false, "2.0.1", "2.0"},
+		{false, "10", "9"},
+		{false, "", "2.0"},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.expected, IsGrandfathered(&AppTransaction{OriginalApplicationVersion: tt.original}, tt.cutoff), "%s < %s", tt.original, tt.cutoff)
+		assert.Equal(tt.expected, IsGrandfathered(&Receipt{OriginalApplicationVersion: tt.original}, tt.cutoff), "%s < %s", tt.original, tt.cutoff)
+	}
+
+	// legacy receipt from verifyReceipt
+	assert.True(IsGrandfathered(testReceipt1, "2.0"))
+	assert.False(IsGrandfathered(testReceipt1, "1.0"))
+}
+
+func TestCompareVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, compareVersion("1.0", "1"))
+	assert.Equal(-1, compareVersion("1.2", "1.10"))
+	assert.Equal(1, compareVersion("1.10", "1.2"))
+	assert.Equal(-1, compareVersion("1.0b", "1.0c"))
+}
@@ -0,0 +1,291 @@
+package appstoretest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+// ReceiptBuilder builds the response of verifyReceipt for tests.
+// The methods for subscriptions (Trial, Upgrade, AutoRenewOff, BillingRetry and AutoRenewProduct)
+// change the last added subscription, and Refund changes the last added transaction.
+//
+//	receipt := appstoretest.NewReceiptBuilder("com.example.app").
+//		At(now).
+//		Consumable("coin", now.Add(-time.Hour)).
+//		Subscription("monthly", now.AddDate(0, 0, -100), 30*24*time.Hour, 3).
+//		Trial().
+//		BillingRetry().
+//		Receipt()
+type ReceiptBuilder struct {
+	bundleID    string
+	environment string
+	status      int
+	now         time.Time
+	nextID      int64
+
+	u       *user
+	last    *Transaction
+	chain   int64
+	catalog appstore.ProductCatalog
+}
+
+// NewReceiptBuilder creates ReceiptBuilder of the app in sandbox at the current time
+func NewReceiptBuilder(bundleID string) *ReceiptBuilder {
+	return &ReceiptBuilder{
+		bundleID:    bundleID,
+		environment: Sandbox,
+		now:         time.Now().Truncate(time.Second),
+		nextID:      firstTransactionID,
+		u: &user{
+			renewals: make(map[int64]*renewal),
+		},
+		catalog: make(appstore.ProductCatalog),
+	}
+}
+
+// At sets the request date of the receipt, which is used to check the expiration in iOS 6 style response
+func (b *ReceiptBuilder) At(t time.Time) *ReceiptBuilder {
+	b.now = t.Truncate(time.Second)
+	return b
+}
+
+// Environment sets the environment (Sandbox or Production)
+func (b *ReceiptBuilder) Environment(environment string) *ReceiptBuilder {
+	b.environment = environment
+	return b
+}
+
+// Status sets the status of the response
+func (b *ReceiptBuilder) Status(status int) *ReceiptBuilder {
+	b.status = status
+	return b
+}
+
+// Consumable adds the purchase of the consumable product.
+// The receipt doesn't have the product type, so the purchase is the same as NonConsumable in the receipt,
+// and the type is recorded in Catalog.
+func (b *ReceiptBuilder) Consumable(productID string, purchaseDate time.Time) *ReceiptBuilder {
+	b.purchase(productID, Consumable, purchaseDate, time.Time{})
+	return b
+}
+
+// NonConsumable adds the purchase of the non-consumable product
+func (b *ReceiptBuilder) NonConsumable(productID string, purchaseDate time.Time) *ReceiptBuilder {
+	b.purchase(productID, NonConsumable, purchaseDate, time.Time{})
+	return b
+}
+
+// NonRenewing adds the purchase of the non-renewing subscription which expires after the period
+func (b *ReceiptBuilder) NonRenewing(productID string, purchaseDate time.Time, period time.Duration) *ReceiptBuilder {
+	purchaseDate = purchaseDate.Truncate(time.Second)
+	b.purchase(productID, NonRenewing, purchaseDate, purchaseDate.Add(period))
+	return b
+}
+
+func (b *ReceiptBuilder) purchase(productID string, productType ProductType, purchaseDate, expiresDate time.Time) {
+	b.catalog[productID] = productType
+	purchaseDate = purchaseDate.Truncate(time.Second)
+	id := b.newID()
+	b.add(&Transaction{
+		TransactionID:         id,
+		OriginalTransactionID: id,
+		OrderID:               orderID(id),
+		ProductID:             productID,
+		Quantity:              1,
+		PurchaseDate:          purchaseDate,
+		OriginalPurchaseDate:  purchaseDate,
+		ExpiresDate:           expiresDate,
+	})
+}
+
+// Subscription adds the auto-renewable subscription which starts at the time and is renewed n times
+func (b *ReceiptBuilder) Subscription(productID string, start time.Time, period time.Duration, renewals int) *ReceiptBuilder {
+	start = start.Truncate(time.Second)
+	b.chain = b.nextID
+	b.u.renewals[b.chain] = &renewal{autoRenew: true}
+	b.renew(productID, start, start, period, renewals)
+	return b
+}
+
+// renew adds the transactions of the subscription chain for the periods
+func (b *ReceiptBuilder) renew(productID string, originalPurchaseDate, start time.Time, period time.Duration, renewals int) {
+	b.catalog[productID] = AutoRenewable
+	for i := 0; i <= renewals; i++ {
+		id := b.newID()
+		b.add(&Transaction{
+			TransactionID:         id,
+			OriginalTransactionID: b.chain,
+			WebOrderLineItemID:    id,
+			OrderID:               orderID(id),
+			ProductID:             productID,
+			Quantity:              1,
+			PurchaseDate:          start,
+			OriginalPurchaseDate:  originalPurchaseDate,
+			ExpiresDate:           start.Add(period),
+		})
+		start = start.Add(period)
+	}
+}
+
+// Trial makes the first period of the subscription a free trial
+func (b *ReceiptBuilder) Trial() *ReceiptBuilder {
+	b.chainTransactions("Trial")[0].IsTrialPeriod = true
+	return b
+}
+
+// Upgrade upgrades the subscription to the product in the same subscription group at the time.
+// The transaction in the period is cancelled, and the later renewals are replaced by the new product.
+func (b *ReceiptBuilder) Upgrade(productID string, at time.Time, period time.Duration, renewals int) *ReceiptBuilder {
+	at = at.Truncate(time.Second)
+	chain := b.chainTransactions("Upgrade")
+	var list []*Transaction
+	for _, tx := range b.u.transactions {
+		if tx.OriginalTransactionID == b.chain && !tx.PurchaseDate.Before(at) {
+			continue
+		}
+		if tx.OriginalTransactionID == b.chain && tx.ExpiresDate.After(at) {
+			tx.IsUpgraded = true
+			tx.CancellationDate = at
+		}
+		list = append(list, tx)
+	}
+	b.u.transactions = list
+
+	b.renew(productID, chain[0].OriginalPurchaseDate, at, period, renewals)
+	b.u.renewals[b.chain].autoRenewProductID = ""
+	return b
+}
+
+// Refund refunds the last added transaction at the time
+func (b *ReceiptBuilder) Refund(at time.Time) *ReceiptBuilder {
+	if b.last == nil {
+		panic("appstoretest: Refund is called before adding transactions")
+	}
+	b.last.CancellationDate = at.Truncate(time.Second)
+	return b
+}
+
+// AutoRenewOff turns off the auto-renewal of the subscription by the user
+func (b *ReceiptBuilder) AutoRenewOff() *ReceiptBuilder {
+	r := b.renewal("AutoRenewOff")
+	r.autoRenew = false
+	r.expirationIntent = "1"
+	return b
+}
+
+// BillingRetry makes the subscription in the billing retry period
+func (b *ReceiptBuilder) BillingRetry() *ReceiptBuilder {
+	r := b.renewal("BillingRetry")
+	r.billingRetry = true
+	r.expirationIntent = "2"
+	return b
+}
+
+// AutoRenewProduct changes the product of the next renewal (e.g. downgrade)
+func (b *ReceiptBuilder) AutoRenewProduct(productID string) *ReceiptBuilder {
+	b.renewal("AutoRenewProduct").autoRenewProductID = productID
+	return b
+}
+
+func (b *ReceiptBuilder) newID() int64 {
+	id := b.nextID
+	b.nextID++
+	return id
+}
+
+func (b *ReceiptBuilder) add(tx *Transaction) {
+	b.u.transactions = append(b.u.transactions, tx)
+	b.last = tx
+}
+
+// chainTransactions returns the transactions of the last added subscription
+func (b *ReceiptBuilder) chainTransactions(method string) []*Transaction {
+	b.renewal(method)
+	var list []*Transaction
+	for _, tx := range b.u.transactions {
+		if tx.OriginalTransactionID == b.chain {
+			list = append(list, tx)
+		}
+	}
+	return list
+}
+
+func (b *ReceiptBuilder) renewal(method string) *renewal {
+	r, ok := b.u.renewals[b.chain]
+	if !ok {
+		panic("appstoretest: " + method + " is called before Subscription")
+	}
+	return r
+}
+
+// Transactions returns all of the added transactions
+func (b *ReceiptBuilder) Transactions() []Transaction {
+	list := make([]Transaction, len(b.u.transactions))
+	for i, tx := range b.u.transactions {
+		list[i] = *tx
+	}
+	return list
+}
+
+// Catalog returns the types of the added products
+func (b *ReceiptBuilder) Catalog() appstore.ProductCatalog {
+	catalog := make(appstore.ProductCatalog, len(b.catalog))
+	for id, t := range b.catalog {
+		catalog[id] = t
+	}
+	return catalog
+}
+
+// user returns the user whose receipt is created at the first purchase
+func (b *ReceiptBuilder) user() *user {
+	b.u.createdAt = b.now
+	for _, tx := range b.u.transactions {
+		if tx.PurchaseDate.Before(b.u.createdAt) {
+			b.u.createdAt = tx.PurchaseDate
+		}
+	}
+	return b.u
+}
+
+// IOS7 returns iOS 7 style response
+func (b *ReceiptBuilder) IOS7() *appstore.IAPResponseIOS7 {
+	resp := b.user().ios7(b.bundleID, b.environment, b.now)
+	if b.status != 0 {
+		resp.Status = b.status
+	}
+	return &resp
+}
+
+// IOS6 returns iOS 6 style response.
+// The status is 21006 when the last subscription is expired.
+func (b *ReceiptBuilder) IOS6() *appstore.IAPResponseIOS6 {
+	resp := b.user().ios6(b.bundleID, b.now)
+	if b.status != 0 {
+		resp.Status = b.status
+	}
+	return &resp
+}
+
+// IOS7JSON returns iOS 7 style response in JSON
+func (b *ReceiptBuilder) IOS7JSON() string {
+	data, _ := json.Marshal(b.IOS7())
+	return string(data)
+}
+
+// IOS6JSON returns iOS 6 style response in JSON
+func (b *ReceiptBuilder) IOS6JSON() string {
+	data, _ := json.Marshal(b.IOS6())
+	return string(data)
+}
+
+// Receipt returns Receipt of iOS 7 style response
+func (b *ReceiptBuilder) Receipt() *appstore.Receipt {
+	return b.IOS7().ToReceipt()
+}
+
+// IOS6Receipt returns Receipt of iOS 6 style response
+func (b *ReceiptBuilder) IOS6Receipt() *appstore.Receipt {
+	return b.IOS6().ToIOS7().ToReceipt()
+}
@@ -0,0 +1,216 @@
+package appstoretest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+const testPeriod = 30 * 24 * time.Hour
+
+var testNow = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestReceiptBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	start := testNow.Add(-100 * 24 * time.Hour)
+	r := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Environment(Production).
+		Consumable("coin", testNow.Add(-time.Hour)).
+		NonConsumable("premium", start).
+		Subscription("monthly", start, testPeriod, 3).
+		Trial().
+		Receipt()
+
+	assert.NoError(r.HasError())
+	assert.Equal(Production, r.Environment)
+	assert.Equal(testBundleID, r.BundleID)
+	assert.True(start.Equal(r.OriginalPurchaseDate))
+	assert.Len(r.InApps, 6)
+	assert.Equal("coin", r.InApps[0].ProductID)
+	assert.True(r.InApps[0].ExpiresDate.IsZero())
+
+	assert.Len(r.LatestReceiptInfo, 4)
+	latest := r.LatestReceiptInfo[3]
+	assert.True(start.Add(4 * testPeriod).Equal(latest.ExpiresDate))
+	assert.Equal(r.InApps[2].TransactionID, latest.OriginalTransactionID)
+	assert.True(r.InApps[2].IsTrialPeriod)
+	assert.False(latest.IsTrialPeriod)
+	assert.Equal(latest, r.GetLastExpiresByProductID("monthly"))
+	assert.True(r.PendingRenewalInfo.IsAutoRenewStatusOn("monthly"))
+}
+
+func TestReceiptBuilderRenewalOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	start := testNow.Add(-100 * 24 * time.Hour)
+	b := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Subscription("monthly", start, testPeriod, 2)
+	txs := b.Transactions()
+	r := b.Receipt()
+
+	// oldest first, as the App Store
+	assert.Equal([]int64{txs[0].TransactionID, txs[1].TransactionID, txs[2].TransactionID}, r.LatestReceiptInfo.TransactionIDs())
+	assert.Equal(txs[2].TransactionID, r.LatestReceiptInfo.LastExpiresByProductIDForLatest("monthly").TransactionID)
+	ids := []int64{txs[0].TransactionID, txs[1].TransactionID, txs[2].TransactionID}
+	assert.Equal(txs[2].TransactionID, r.LatestReceiptInfo.LastExpiresByTransactionIDsForLatest(ids).TransactionID)
+}
+
+func TestReceiptBuilderCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	b := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Consumable("coin", testNow.Add(-time.Hour)).
+		NonConsumable("premium", testNow.Add(-time.Hour)).
+		NonRenewing("season", testNow.Add(-time.Hour), testPeriod).
+		Subscription("monthly", testNow.Add(-time.Hour), testPeriod, 0).
+		Upgrade("monthly_pro", testNow.Add(-time.Minute), testPeriod, 0)
+
+	assert.Equal(appstore.ProductCatalog{
+		"coin":        Consumable,
+		"premium":     NonConsumable,
+		"season":      NonRenewing,
+		"monthly":     AutoRenewable,
+		"monthly_pro": AutoRenewable,
+	}, b.Catalog())
+
+	r := b.Receipt()
+	assert.Equal([]int64{r.InApps[0].TransactionID}, r.UnfinishedConsumables(b.Catalog()).TransactionIDs())
+}
+
+func TestReceiptBuilderUpgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	start := testNow.Add(-50 * 24 * time.Hour)
+	upgradeAt := start.Add(testPeriod + 10*24*time.Hour)
+	b := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Subscription("monthly", start, testPeriod, 3).
+		Upgrade("monthly_pro", upgradeAt, testPeriod, 0)
+
+	txs := b.Transactions()
+	assert.Len(txs, 3)
+	assert.False(txs[0].IsUpgraded)
+	assert.True(txs[1].IsUpgraded)
+	assert.True(upgradeAt.Equal(txs[1].CancellationDate))
+	assert.Equal("monthly_pro", txs[2].ProductID)
+	assert.Equal(txs[0].OriginalTransactionID, txs[2].OriginalTransactionID)
+	assert.True(start.Equal(txs[2].OriginalPurchaseDate))
+
+	r := b.Receipt()
+	assert.Equal([]int64{txs[2].TransactionID}, r.GetTransactionIDsByProductWithoutExpiredAt("monthly_pro", testNow))
+	assert.Len(r.PendingRenewalInfo, 1)
+	assert.Equal("monthly_pro", r.PendingRenewalInfo[0].ProductID)
+	assert.Equal("monthly_pro", r.PendingRenewalInfo[0].AutoRenewProductID)
+}
+
+func TestReceiptBuilderPendingRenewalInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Subscription("monthly", testNow.Add(-10*24*time.Hour), testPeriod, 0).
+		AutoRenewProduct("monthly_lite").
+		Subscription("weekly", testNow.Add(-10*24*time.Hour), 7*24*time.Hour, 1).
+		BillingRetry().
+		Refund(testNow.Add(-time.Hour)).
+		Subscription("yearly", testNow.Add(-time.Hour), 365*24*time.Hour, 0).
+		AutoRenewOff().
+		Receipt()
+
+	infos := r.PendingRenewalInfo
+	assert.Len(infos, 3)
+	yearly := infos.GetRenewalInfo("yearly")
+	assert.False(yearly.AutoRenewStatus)
+	assert.Equal(int64(1), yearly.ExpirationIntent)
+	weekly := infos.GetRenewalInfo("weekly")
+	assert.True(weekly.RetryFlag)
+	assert.Equal(int64(2), weekly.ExpirationIntent)
+	monthly := infos.GetRenewalInfo("monthly_lite")
+	assert.True(monthly.IsDifferentAutoRenewProductID())
+	assert.True(monthly.AutoRenewStatus)
+
+	refunded := r.GetLastExpiresByProductID("weekly")
+	assert.True(testNow.Add(-time.Hour).Equal(refunded.CancellationDate))
+}
+
+func TestReceiptBuilderIOS6(t *testing.T) {
+	assert := assert.New(t)
+
+	start := testNow.Add(-100 * 24 * time.Hour)
+	b := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Subscription("monthly", start, testPeriod, 1).
+		AutoRenewOff()
+
+	resp := b.IOS6()
+	assert.Equal(21006, resp.Status)
+	assert.Equal("", resp.LatestReceiptInfo.TransactionID)
+	assert.Equal(resp.Receipt.TransactionID, resp.LatestExpiredReceiptInfo.TransactionID)
+	assert.Equal(0, resp.AutoRenewStatus)
+
+	r := b.IOS6Receipt()
+	assert.Equal(6, r.ResponseVersion())
+	assert.Equal(testBundleID, r.BundleID)
+	assert.True(start.Add(2 * testPeriod).Equal(r.LatestReceiptInfo[0].ExpiresDate))
+	assert.True(r.PendingRenewalInfo.IsAutoRenewStatusOff("monthly"))
+
+	// active subscription
+	resp = b.At(start.Add(testPeriod + time.Hour)).IOS6()
+	assert.Equal(0, resp.Status)
+	assert.Equal(resp.Receipt.TransactionID, resp.LatestReceiptInfo.TransactionID)
+
+	resp = NewReceiptBuilder(testBundleID).Status(21003).IOS6()
+	assert.Equal(21003, resp.Status)
+}
+
+func TestReceiptBuilderJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	b := NewReceiptBuilder(testBundleID).
+		At(testNow).
+		Consumable("coin", testNow.Add(-time.Hour)).
+		Subscription("monthly", testNow.Add(-40*24*time.Hour), testPeriod, 1).
+		Trial()
+
+	for _, tt := range []struct {
+		body     string
+		expected *appstore.Receipt
+	}{
+		{b.IOS7JSON(), b.Receipt()},
+		{b.IOS6JSON(), b.IOS6Receipt()},
+	} {
+		body := tt.body
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, body)
+		}))
+		client := appstore.New()
+		client.URL = server.URL
+		r, err := client.Verify(appstore.IAPRequest{ReceiptData: "dummy data"})
+		server.Close()
+
+		assert.NoError(err)
+		assert.Equal(tt.expected.ResponseVersion(), r.ResponseVersion())
+		assert.Equal(tt.expected.Status, r.Status)
+		assert.Equal(tt.expected.InApps, r.InApps)
+		assert.Equal(tt.expected.LatestReceiptInfo, r.LatestReceiptInfo)
+		assert.Equal(tt.expected.PendingRenewalInfo, r.PendingRenewalInfo)
+	}
+}
+
+func TestReceiptBuilderPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { NewReceiptBuilder(testBundleID).Trial() })
+	assert.Panics(func() { NewReceiptBuilder(testBundleID).Refund(testNow) })
+	assert.Panics(func() { NewReceiptBuilder(testBundleID).Consumable("coin", testNow).BillingRetry() })
+}
@@ -0,0 +1,150 @@
+package appstoretest
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+const dateLayout = "2006-01-02 15:04:05"
+
+// pacific is used for `*_pst` fields of the receipt
+var pacific = loadPacific()
+
+func loadPacific() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*60*60)
+	}
+	return loc
+}
+
+// ProductType is the type of in-app purchase, which is the same as appstore.ProductType
+type ProductType = appstore.ProductType
+
+// the types of in-app purchase products
+const (
+	Consumable    = appstore.ProductTypeConsumable
+	NonConsumable = appstore.ProductTypeNonConsumable
+	AutoRenewable = appstore.ProductTypeAutoRenewable
+	NonRenewing   = appstore.ProductTypeNonRenewing
+)
+
+// jwsType returns the type in the signed transaction of App Store Server API
+func jwsType(t ProductType) string {
+	switch t {
+	case Consumable:
+		return "Consumable"
+	case NonConsumable:
+		return "Non-Consumable"
+	case AutoRenewable:
+		return "Auto-Renewable Subscription"
+	case NonRenewing:
+		return "Non-Renewing Subscription"
+	}
+	return ""
+}
+
+// Product is an in-app purchase product registered in App Store Connect
+type Product struct {
+	ID   string
+	Type ProductType
+	// Duration is the period of subscriptions
+	Duration time.Duration
+}
+
+// Transaction is a purchase of the product
+type Transaction struct {
+	TransactionID         int64
+	OriginalTransactionID int64
+	WebOrderLineItemID    int64
+	OrderID               string
+	ProductID             string
+	Quantity              int64
+	IsTrialPeriod         bool
+	IsUpgraded            bool
+	PurchaseDate          time.Time
+	OriginalPurchaseDate  time.Time
+	ExpiresDate           time.Time
+	CancellationDate      time.Time
+}
+
+// IsRefunded checks the transaction is refunded by Apple customer support or not
+func (tx Transaction) IsRefunded() bool {
+	return !tx.CancellationDate.IsZero()
+}
+
+// InApp converts the transaction into the format of verifyReceipt response
+func (tx Transaction) InApp() appstore.InApp {
+	v := appstore.InApp{
+		Quantity:              strconv.FormatInt(tx.Quantity, 10),
+		ProductID:             tx.ProductID,
+		TransactionID:         strconv.FormatInt(tx.TransactionID, 10),
+		OriginalTransactionID: strconv.FormatInt(tx.OriginalTransactionID, 10),
+		IsTrialPeriod:         strconv.FormatBool(tx.IsTrialPeriod),
+		IsInIntroOfferPeriod:  "false",
+		InAppOwnershipType:    "PURCHASED",
+	}
+	if tx.WebOrderLineItemID != 0 {
+		v.WebOrderLineItemID = strconv.FormatInt(tx.WebOrderLineItemID, 10)
+	}
+	v.PurchaseDate.PurchaseDate, v.PurchaseDate.PurchaseDateMS, v.PurchaseDate.PurchaseDatePST = formatDate(tx.PurchaseDate)
+	v.OriginalPurchaseDate.OriginalPurchaseDate, v.OriginalPurchaseDate.OriginalPurchaseDateMS, v.OriginalPurchaseDate.OriginalPurchaseDatePST = formatDate(tx.OriginalPurchaseDate)
+	v.ExpiresDate.ExpiresDate, v.ExpiresDate.ExpiresDateMS, v.ExpiresDate.ExpiresDatePST = formatDate(tx.ExpiresDate)
+	v.CancellationDate.CancellationDate, v.CancellationDate.CancellationDateMS, v.CancellationDate.CancellationDatePST = formatDate(tx.CancellationDate)
+	return v
+}
+
+// JWSTransaction converts the transaction into the payload of signed transaction information
+func (tx Transaction) JWSTransaction(bundleID, environment string, productType ProductType, signedDate time.Time) appstore.JWSTransaction {
+	v := appstore.JWSTransaction{
+		TransactionID:         strconv.FormatInt(tx.TransactionID, 10),
+		OriginalTransactionID: strconv.FormatInt(tx.OriginalTransactionID, 10),
+		BundleID:              bundleID,
+		ProductID:             tx.ProductID,
+		PurchaseDate:          toMS(tx.PurchaseDate),
+		OriginalPurchaseDate:  toMS(tx.OriginalPurchaseDate),
+		ExpiresDate:           toMS(tx.ExpiresDate),
+		Quantity:              tx.Quantity,
+		Type:                  jwsType(productType),
+		InAppOwnershipType:    "PURCHASED",
+		SignedDate:            toMS(signedDate),
+		RevocationDate:        toMS(tx.CancellationDate),
+		IsUpgraded:            tx.IsUpgraded,
+		Environment:           environment,
+		TransactionReason:     "PURCHASE",
+	}
+	if tx.WebOrderLineItemID != 0 {
+		v.WebOrderLineItemID = strconv.FormatInt(tx.WebOrderLineItemID, 10)
+	}
+	if tx.IsTrialPeriod {
+		v.OfferType = 1
+		v.OfferDiscountType = "FREE_TRIAL"
+	}
+	if tx.IsRefunded() {
+		reason := int64(0)
+		v.RevocationReason = &reason
+	}
+	if tx.TransactionID != tx.OriginalTransactionID {
+		v.TransactionReason = "RENEWAL"
+	}
+	return v
+}
+
+// formatDate returns the date in GMT, milliseconds and PST as the App Store does
+func formatDate(t time.Time) (date, ms, pst string) {
+	if t.IsZero() {
+		return "", "", ""
+	}
+	return t.UTC().Format(dateLayout) + " Etc/GMT",
+		strconv.FormatInt(toMS(t), 10),
+		t.In(pacific).Format(dateLayout) + " America/Los_Angeles"
+}
+
+func toMS(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix() * 1000
+}
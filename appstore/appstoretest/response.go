@@ -0,0 +1,165 @@
+package appstoretest
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+// user has the transactions in the receipt
+type user struct {
+	id           string
+	environment  string
+	receiptData  string
+	createdAt    time.Time
+	transactions []*Transaction
+	renewals     map[int64]*renewal
+}
+
+// renewal is the state of the subscription chain for pending_renewal_info
+type renewal struct {
+	autoRenew          bool
+	autoRenewProductID string
+	billingRetry       bool
+	expirationIntent   string
+}
+
+// subscriptions returns the transactions of auto-renewable subscriptions sorted by purchase date in ascending order,
+// as latest_receipt_info of the App Store
+func (u *user) subscriptions() []*Transaction {
+	var list []*Transaction
+	for _, tx := range u.transactions {
+		if _, ok := u.renewals[tx.OriginalTransactionID]; ok {
+			list = append(list, tx)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].PurchaseDate.Before(list[j].PurchaseDate)
+	})
+	return list
+}
+
+// pendingRenewalInfo returns pending_renewal_info of the subscription chain whose latest transaction is tx
+func (u *user) pendingRenewalInfo(tx *Transaction) appstore.PendingRenewalInfo {
+	r := u.renewals[tx.OriginalTransactionID]
+	info := appstore.PendingRenewalInfo{
+		ExpirationIntent:   r.expirationIntent,
+		AutoRenewProductID: r.autoRenewProductID,
+		RetryFlag:          boolFlag(r.billingRetry),
+		AutoRenewStatus:    boolFlag(r.autoRenew),
+		ProductID:          tx.ProductID,
+	}
+	if info.AutoRenewProductID == "" {
+		info.AutoRenewProductID = tx.ProductID
+	}
+	return info
+}
+
+// ios7 builds iOS 7 style response of verifyReceipt
+func (u *user) ios7(bundleID, environment string, now time.Time) appstore.IAPResponseIOS7 {
+	resp := appstore.IAPResponseIOS7{
+		Status:      0,
+		Environment: environment,
+	}
+	rc := &resp.Receipt
+	rc.ReceiptType = "Production"
+	if environment == Sandbox {
+		rc.ReceiptType = "ProductionSandbox"
+	}
+	rc.BundleID = bundleID
+	rc.ApplicationVersion = "1"
+	rc.OriginalApplicationVersion = "1.0"
+	rc.RequestDate.RequestDate, rc.RequestDate.RequestDateMS, rc.RequestDate.RequestDatePST = formatDate(now)
+	rc.OriginalPurchaseDate.OriginalPurchaseDate, rc.OriginalPurchaseDate.OriginalPurchaseDateMS, rc.OriginalPurchaseDate.OriginalPurchaseDatePST = formatDate(u.createdAt)
+	rc.ReceiptCreationDate.ReceiptCreationDate, rc.ReceiptCreationDate.ReceiptCreationDateMS, rc.ReceiptCreationDate.ReceiptCreationDatePST = formatDate(now)
+
+	rc.InApp = []appstore.InApp{}
+	for _, tx := range u.transactions {
+		rc.InApp = append(rc.InApp, tx.InApp())
+	}
+
+	subscriptions := u.subscriptions()
+	for _, tx := range subscriptions {
+		resp.LatestReceiptInfo = append(resp.LatestReceiptInfo, tx.InApp())
+	}
+	// pending_renewal_info of each subscription chain is built from the latest transaction
+	seen := make(map[int64]bool)
+	for i := len(subscriptions) - 1; i >= 0; i-- {
+		tx := subscriptions[i]
+		if seen[tx.OriginalTransactionID] {
+			continue
+		}
+		seen[tx.OriginalTransactionID] = true
+		resp.PendingRenewalInfo = append(resp.PendingRenewalInfo, u.pendingRenewalInfo(tx))
+	}
+	return resp
+}
+
+// ios6 builds iOS 6 style response of verifyReceipt.
+// The receipt is the latest transaction, and the status is 21006 when the latest subscription is expired.
+func (u *user) ios6(bundleID string, now time.Time) appstore.IAPResponseIOS6 {
+	resp := appstore.IAPResponseIOS6{Status: 0}
+	if len(u.transactions) == 0 {
+		return resp
+	}
+
+	latest := u.transactions[0]
+	for _, tx := range u.transactions[1:] {
+		if !tx.PurchaseDate.Before(latest.PurchaseDate) {
+			latest = tx
+		}
+	}
+	resp.Receipt = latest.receiptIOS6(bundleID, now)
+
+	subscriptions := u.subscriptions()
+	if len(subscriptions) == 0 {
+		return resp
+	}
+	tx := subscriptions[len(subscriptions)-1]
+	switch {
+	case tx.ExpiresDate.After(now):
+		resp.LatestReceiptInfo = tx.receiptIOS6(bundleID, now)
+	default:
+		resp.Status = 21006
+		resp.LatestExpiredReceiptInfo = tx.receiptIOS6(bundleID, now)
+	}
+	info := u.pendingRenewalInfo(tx)
+	resp.AutoRenewStatus, _ = strconv.Atoi(info.AutoRenewStatus)
+	resp.AutoRenewProductID = info.AutoRenewProductID
+	resp.ExpirationIntent = info.ExpirationIntent
+	resp.RetryFlag = info.RetryFlag
+	return resp
+}
+
+// receiptIOS6 converts the transaction into iOS 6 style receipt
+func (tx Transaction) receiptIOS6(bundleID string, now time.Time) appstore.ReceiptIOS6 {
+	v := tx.InApp()
+	rc := appstore.ReceiptIOS6{
+		BundleID:                   bundleID,
+		ApplicationVersion:         "1",
+		OriginalApplicationVersion: "1.0",
+		OriginalTransactionID:      v.OriginalTransactionID,
+		ProductID:                  v.ProductID,
+		Quantity:                   v.Quantity,
+		TransactionID:              v.TransactionID,
+		WebOrderLineItemID:         v.WebOrderLineItemID,
+		ExpiresDate:                v.ExpiresDate.ExpiresDate,
+		ExpiresDateMS:              v.ExpiresDate.ExpiresDateMS,
+		ExpiresDatePST:             v.ExpiresDate.ExpiresDatePST,
+		PurchaseDate:               v.PurchaseDate,
+		OriginalPurchaseDate:       v.OriginalPurchaseDate,
+		IsTrialPeriod:              v.IsTrialPeriod,
+		IsInIntroOfferPeriod:       v.IsInIntroOfferPeriod,
+	}
+	rc.RequestDate.RequestDate, rc.RequestDate.RequestDateMS, rc.RequestDate.RequestDatePST = formatDate(now)
+	return rc
+}
+
+func boolFlag(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,594 @@
+// Package appstoretest provides a fake App Store for testing.
+//
+// Server is a stateful fake of verifyReceipt and App Store Server API.
+// Register products and users, simulate purchases, renewals, refunds and billing retry,
+// then verify the receipt with appstore.Client or call appstore.ServerAPIClient without network access.
+//
+// ReceiptBuilder builds the response of verifyReceipt directly in JSON or *appstore.Receipt.
+package appstoretest
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+const (
+	Sandbox    = "Sandbox"
+	Production = "Production"
+
+	firstTransactionID = 1000000000
+)
+
+var (
+	// ErrUserNotFound is returned when the user is not registered
+	ErrUserNotFound = errors.New("The user is not found.")
+	// ErrProductNotFound is returned when the product is not registered
+	ErrProductNotFound = errors.New("The product is not found.")
+	// ErrTransactionNotFound is returned when the transaction is not found
+	ErrTransactionNotFound = errors.New("The transaction is not found.")
+	// ErrNotSubscription is returned when the transaction is not an auto-renewable subscription
+	ErrNotSubscription = errors.New("The transaction is not an auto-renewable subscription.")
+)
+
+// Server is a fake App Store which serves verifyReceipt and App Store Server API
+type Server struct {
+	*httptest.Server
+
+	// BundleID is the bundle id of the app in the receipts
+	BundleID string
+	signer   *Signer
+
+	mu           sync.Mutex
+	now          time.Time
+	password     string
+	failures     []int
+	nextID       int64
+	products     map[string]Product
+	users        map[string]*user
+	receipts     map[string]*user
+	transactions map[int64]*user
+}
+
+// errorResponse is the response of verifyReceipt without receipt
+type errorResponse struct {
+	Status      int    `json:"status"`
+	Environment string `json:"environment,omitempty"`
+	IsRetryable bool   `json:"is_retryable,omitempty"`
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer(bundleID string) *Server {
+	signer, err := NewSigner()
+	if err != nil {
+		panic("appstoretest: failed to create signer: " + err.Error())
+	}
+
+	s := &Server{
+		BundleID:     bundleID,
+		signer:       signer,
+		nextID:       firstTransactionID,
+		products:     make(map[string]Product),
+		users:        make(map[string]*user),
+		receipts:     make(map[string]*user),
+		transactions: make(map[int64]*user),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/verifyReceipt", s.handleVerifyReceipt(Production))
+	mux.HandleFunc("/sandbox/verifyReceipt", s.handleVerifyReceipt(Sandbox))
+	mux.HandleFunc("/inApps/", s.handleServerAPI)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ProductionURL returns the URL of verifyReceipt in production
+func (s *Server) ProductionURL() string {
+	return s.URL + "/verifyReceipt"
+}
+
+// SandboxURL returns the URL of verifyReceipt in sandbox
+func (s *Server) SandboxURL() string {
+	return s.URL + "/sandbox/verifyReceipt"
+}
+
+// Signer returns the signer of the signed transactions
+func (s *Server) Signer() *Signer {
+	return s.signer
+}
+
+// Now returns the current time of the server
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current()
+}
+
+// SetNow fixes the current time of the server.
+// When t is zero, the server uses the system clock.
+func (s *Server) SetNow(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = t.Truncate(time.Second)
+}
+
+// Advance moves the current time of the server forward
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.current().Add(d)
+}
+
+// current returns the current time in the precision of receipts
+func (s *Server) current() time.Time {
+	if s.now.IsZero() {
+		return time.Now().Truncate(time.Second)
+	}
+	return s.now
+}
+
+// SetPassword sets the shared secret of the app.
+// When it's set, verifyReceipt with the different password responds 21004.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// FailNext makes the next verifyReceipt calls respond the statuses in order (e.g. 21005, 21100).
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Catalog returns the types of the registered products
+func (s *Server) Catalog() appstore.ProductCatalog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	catalog := make(appstore.ProductCatalog, len(s.products))
+	for id, p := range s.products {
+		catalog[id] = p.Type
+	}
+	return catalog
+}
+
+// AddProduct registers the product
+func (s *Server) AddProduct(p Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products[p.ID] = p
+}
+
+// AddUser registers the user in the environment (Sandbox or Production) and returns the receipt data of the user
+func (s *Server) AddUser(userID, environment string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		delete(s.receipts, u.receiptData)
+	}
+	u := &user{
+		id:          userID,
+		environment: environment,
+		receiptData: newReceiptData(environment, userID),
+		createdAt:   s.current(),
+		renewals:    make(map[int64]*renewal),
+	}
+	s.users[userID] = u
+	s.receipts[u.receiptData] = u
+	return u.receiptData
+}
+
+// the content types of PKCS#7 for the receipt data
+var (
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+)
+
+// newReceiptData returns base64 encoded PKCS#7 signed data without signers,
+// so that the receipt data passes appstore.CheckReceiptData
+func newReceiptData(environment, userID string) string {
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     []byte `asn1:"explicit,tag:0"`
+	}
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	signed, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      contentInfo
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo: contentInfo{
+			ContentType: oidData,
+			Content:     []byte("appstoretest:" + environment + ":" + userID),
+		},
+		SignerInfos: emptySet,
+	})
+	if err != nil {
+		panic("appstoretest: failed to create receipt data: " + err.Error())
+	}
+	data, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+	if err != nil {
+		panic("appstoretest: failed to create receipt data: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// ReceiptData returns the receipt data of the user
+func (s *Server) ReceiptData(userID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	return u.receiptData, nil
+}
+
+// Transactions returns all of the transactions of the user in the order of purchase
+func (s *Server) Transactions(userID string) ([]Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	list := make([]Transaction, len(u.transactions))
+	for i, tx := range u.transactions {
+		list[i] = *tx
+	}
+	return list, nil
+}
+
+// Purchase buys the product by the user.
+// The subscription starts from now and auto-renewal is enabled.
+func (s *Server) Purchase(userID, productID string) (Transaction, error) {
+	return s.purchase(userID, productID, false)
+}
+
+// PurchaseTrial starts the free trial of the subscription by the user
+func (s *Server) PurchaseTrial(userID, productID string) (Transaction, error) {
+	return s.purchase(userID, productID, true)
+}
+
+func (s *Server) purchase(userID, productID string, trial bool) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return Transaction{}, ErrUserNotFound
+	}
+	p, ok := s.products[productID]
+	if !ok {
+		return Transaction{}, ErrProductNotFound
+	}
+
+	now := s.current()
+	id := s.newID()
+	tx := &Transaction{
+		TransactionID:         id,
+		OriginalTransactionID: id,
+		OrderID:               orderID(id),
+		ProductID:             p.ID,
+		Quantity:              1,
+		IsTrialPeriod:         trial,
+		PurchaseDate:          now,
+		OriginalPurchaseDate:  now,
+	}
+	switch p.Type {
+	case AutoRenewable:
+		tx.WebOrderLineItemID = id
+		tx.ExpiresDate = now.Add(p.Duration)
+		u.renewals[id] = &renewal{autoRenew: true}
+	case NonRenewing:
+		tx.ExpiresDate = now.Add(p.Duration)
+	}
+	s.add(u, tx)
+	return *tx, nil
+}
+
+// Renew renews the subscription of the original transaction id for the next period.
+// The billing retry period is finished by the renewal.
+func (s *Server) Renew(originalTransactionID int64) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, latest, err := s.latestSubscription(originalTransactionID)
+	if err != nil {
+		return Transaction{}, err
+	}
+	p := s.products[latest.ProductID]
+
+	start := latest.ExpiresDate
+	if now := s.current(); now.After(start) {
+		start = now
+	}
+	id := s.newID()
+	tx := &Transaction{
+		TransactionID:         id,
+		OriginalTransactionID: latest.OriginalTransactionID,
+		WebOrderLineItemID:    id,
+		OrderID:               orderID(id),
+		ProductID:             latest.ProductID,
+		Quantity:              1,
+		PurchaseDate:          start,
+		OriginalPurchaseDate:  latest.OriginalPurchaseDate,
+		ExpiresDate:           start.Add(p.Duration),
+	}
+	s.add(u, tx)
+	*u.renewals[originalTransactionID] = renewal{autoRenew: true}
+	return *tx, nil
+}
+
+// Refund refunds the transaction by Apple customer support
+func (s *Server) Refund(transactionID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.transactions[transactionID]
+	if !ok {
+		return ErrTransactionNotFound
+	}
+	for _, tx := range u.transactions {
+		if tx.TransactionID == transactionID {
+			tx.CancellationDate = s.current()
+		}
+	}
+	return nil
+}
+
+// StartBillingRetry fails the renewal of the subscription by billing error,
+// and the App Store tries to renew it in the billing retry period.
+func (s *Server) StartBillingRetry(originalTransactionID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, _, err := s.latestSubscription(originalTransactionID)
+	if err != nil {
+		return err
+	}
+	r := u.renewals[originalTransactionID]
+	r.billingRetry = true
+	r.expirationIntent = "2"
+	return nil
+}
+
+// SetAutoRenew turns on or off the auto-renewal of the subscription by the user
+func (s *Server) SetAutoRenew(originalTransactionID int64, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, _, err := s.latestSubscription(originalTransactionID)
+	if err != nil {
+		return err
+	}
+	r := u.renewals[originalTransactionID]
+	r.autoRenew = enabled
+	r.expirationIntent = ""
+	if !enabled {
+		r.expirationIntent = "1"
+	}
+	return nil
+}
+
+func (s *Server) newID() int64 {
+	id := s.nextID
+	s.nextID++
+	return id
+}
+
+func orderID(id int64) string {
+	return "MT" + strconv.FormatInt(id, 10)
+}
+
+func (s *Server) add(u *user, tx *Transaction) {
+	u.transactions = append(u.transactions, tx)
+	s.transactions[tx.TransactionID] = u
+}
+
+// latestSubscription returns the latest transaction of the subscription chain
+func (s *Server) latestSubscription(originalTransactionID int64) (*user, *Transaction, error) {
+	u, ok := s.transactions[originalTransactionID]
+	if !ok {
+		return nil, nil, ErrTransactionNotFound
+	}
+	if _, ok := u.renewals[originalTransactionID]; !ok {
+		return nil, nil, ErrNotSubscription
+	}
+	var latest *Transaction
+	for _, tx := range u.transactions {
+		if tx.OriginalTransactionID == originalTransactionID {
+			latest = tx
+		}
+	}
+	return u, latest, nil
+}
+
+func (s *Server) handleVerifyReceipt(environment string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req appstore.IAPRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusOK, errorResponse{Status: 21000})
+			return
+		}
+
+		s.mu.Lock()
+		resp := s.verifyReceipt(req, environment)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// verifyReceipt builds the response of verifyReceipt in the environment
+func (s *Server) verifyReceipt(req appstore.IAPRequest, environment string) interface{} {
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		return errorResponse{
+			Status:      status,
+			Environment: environment,
+			IsRetryable: 21100 <= status && status <= 21199,
+		}
+	}
+
+	u, ok := s.receipts[req.ReceiptData]
+	switch {
+	case !ok:
+		return errorResponse{Status: 21002}
+	case s.password != "" && req.Password != s.password:
+		return errorResponse{Status: 21004}
+	case u.environment == Sandbox && environment == Production:
+		return errorResponse{Status: 21007}
+	case u.environment == Production && environment == Sandbox:
+		return errorResponse{Status: 21008}
+	}
+
+	resp := u.ios7(s.BundleID, environment, s.current())
+	resp.LatestReceipt = u.receiptData
+	return resp
+}
+
+// handleServerAPI serves the endpoints of App Store Server API supported by appstore.ServerAPIClient
+func (s *Server) handleServerAPI(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/inApps/v1/lookup/"):
+		s.lookUpOrderID(w, strings.TrimPrefix(path, "/inApps/v1/lookup/"))
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/inApps/v2/refund/lookup/"):
+		s.refundHistory(w, strings.TrimPrefix(path, "/inApps/v2/refund/lookup/"))
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/inApps/v1/transactions/consumption/"):
+		s.consumption(w, strings.TrimPrefix(path, "/inApps/v1/transactions/consumption/"))
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/inApps/v1/subscriptions/extend/") && path != "/inApps/v1/subscriptions/extend/mass":
+		s.extendRenewalDate(w, r, strings.TrimPrefix(path, "/inApps/v1/subscriptions/extend/"))
+	default:
+		writeJSON(w, http.StatusNotFound, appstore.ServerAPIError{ErrorCode: 4040000, ErrorMessage: "Not found."})
+	}
+}
+
+func (s *Server) lookUpOrderID(w http.ResponseWriter, orderID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var signed []string
+	for _, u := range s.users {
+		for _, tx := range u.transactions {
+			if tx.OrderID != orderID {
+				continue
+			}
+			v, err := s.sign(u, tx)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, appstore.ServerAPIError{ErrorCode: 5000000, ErrorMessage: err.Error()})
+				return
+			}
+			signed = append(signed, v)
+		}
+	}
+
+	result := map[string]interface{}{"status": 0, "signedTransactions": signed}
+	if len(signed) == 0 {
+		result = map[string]interface{}{"status": 1}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) refundHistory(w http.ResponseWriter, transactionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.transactions[appstore.ToInt64(transactionID)]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, appstore.ServerAPIError{ErrorCode: 4040010, ErrorMessage: "Transaction id not found."})
+		return
+	}
+
+	signed := []string{}
+	for _, tx := range u.transactions {
+		if !tx.IsRefunded() {
+			continue
+		}
+		v, err := s.sign(u, tx)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, appstore.ServerAPIError{ErrorCode: 5000000, ErrorMessage: err.Error()})
+			return
+		}
+		signed = append(signed, v)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"signedTransactions": signed,
+		"revision":           "",
+		"hasMore":            false,
+	})
+}
+
+func (s *Server) consumption(w http.ResponseWriter, transactionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.transactions[appstore.ToInt64(transactionID)]; !ok {
+		writeJSON(w, http.StatusNotFound, appstore.ServerAPIError{ErrorCode: 4040010, ErrorMessage: "Transaction id not found."})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) extendRenewalDate(w http.ResponseWriter, r *http.Request, originalTransactionID string) {
+	var req appstore.ExtendRenewalDateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, appstore.ServerAPIError{ErrorCode: 4000000, ErrorMessage: "Invalid request."})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, latest, err := s.latestSubscription(appstore.ToInt64(originalTransactionID))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, appstore.ServerAPIError{ErrorCode: 4040005, ErrorMessage: "Original transaction id not found."})
+		return
+	}
+	latest.ExpiresDate = latest.ExpiresDate.AddDate(0, 0, int(req.ExtendByDays))
+	writeJSON(w, http.StatusOK, appstore.ExtendRenewalDateResponse{
+		OriginalTransactionID: originalTransactionID,
+		WebOrderLineItemID:    strconv.FormatInt(latest.WebOrderLineItemID, 10),
+		Success:               true,
+		EffectiveDate:         toMS(latest.ExpiresDate),
+	})
+}
+
+// sign returns the signed transaction information of the user's transaction
+func (s *Server) sign(u *user, tx *Transaction) (string, error) {
+	payload := tx.JWSTransaction(s.BundleID, u.environment, s.products[tx.ProductID].Type, s.current())
+	return s.signer.Sign(payload)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,264 @@
+package appstoretest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/evalphobia/go-iap/appstore"
+)
+
+const testBundleID = "com.example.app"
+
+func testServer(t *testing.T) *Server {
+	s := NewServer(testBundleID)
+	s.SetNow(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.AddProduct(Product{ID: "coin", Type: Consumable})
+	s.AddProduct(Product{ID: "premium", Type: NonConsumable})
+	s.AddProduct(Product{ID: "monthly", Type: AutoRenewable, Duration: 30 * 24 * time.Hour})
+	s.AddProduct(Product{ID: "season", Type: NonRenewing, Duration: 90 * 24 * time.Hour})
+	return s
+}
+
+func testVerify(url, receiptData string) (*appstore.Receipt, error) {
+	client := appstore.New()
+	client.URL = url
+	return client.Verify(appstore.IAPRequest{ReceiptData: receiptData})
+}
+
+func TestServerVerifyReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	receiptData := s.AddUser("user1", Production)
+
+	coin, err := s.Purchase("user1", "coin")
+	assert.NoError(err)
+	sub, err := s.Purchase("user1", "monthly")
+	assert.NoError(err)
+	s.Advance(30 * 24 * time.Hour)
+	renewed, err := s.Renew(sub.OriginalTransactionID)
+	assert.NoError(err)
+	assert.Equal(sub.ExpiresDate, renewed.PurchaseDate)
+
+	r, err := testVerify(s.ProductionURL(), receiptData)
+	assert.NoError(err)
+	assert.NoError(r.HasError())
+	assert.Equal(Production, r.Environment)
+	assert.Equal(testBundleID, r.BundleID)
+	assert.Equal(receiptData, r.LatestReceipt)
+	assert.Len(r.InApps, 3)
+	assert.Equal(coin.TransactionID, r.InApps[0].TransactionID)
+	assert.Equal(int64(1), r.InApps[0].Quantity)
+
+	assert.Len(r.LatestReceiptInfo, 2)
+	latest := r.LatestReceiptInfo[1]
+	assert.Equal(renewed.TransactionID, latest.TransactionID)
+	assert.Equal(sub.TransactionID, latest.OriginalTransactionID)
+	assert.True(renewed.ExpiresDate.Equal(latest.ExpiresDate))
+	assert.Equal([]int64{renewed.TransactionID}, r.GetTransactionIDsByProductWithoutExpiredAt("monthly", s.Now().Add(time.Hour)))
+
+	assert.Equal([]int64{coin.TransactionID}, r.UnfinishedConsumables(s.Catalog()).TransactionIDs())
+
+	assert.Len(r.PendingRenewalInfo, 1)
+	assert.True(r.PendingRenewalInfo.IsAutoRenewStatusOn("monthly"))
+	assert.False(r.PendingRenewalInfo[0].RetryFlag)
+}
+
+func TestServerPrecheckReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	receiptData := s.AddUser("user1", Production)
+	assert.NoError(appstore.CheckReceiptData(receiptData))
+	assert.NotEqual(receiptData, s.AddUser("user2", Production))
+
+	client := appstore.NewWithConfig(appstore.Config{PrecheckReceipt: true})
+	client.URL = s.ProductionURL()
+	r, err := client.Verify(appstore.IAPRequest{ReceiptData: receiptData})
+	assert.NoError(err)
+	assert.NoError(r.HasError())
+}
+
+func TestServerLatestReceiptInfoOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	receiptData := s.AddUser("user1", Production)
+
+	sub, _ := s.Purchase("user1", "monthly")
+	s.Advance(30 * 24 * time.Hour)
+	renewed1, _ := s.Renew(sub.OriginalTransactionID)
+	s.Advance(30 * 24 * time.Hour)
+	renewed2, _ := s.Renew(sub.OriginalTransactionID)
+
+	r, err := testVerify(s.ProductionURL(), receiptData)
+	assert.NoError(err)
+	// oldest first, as the App Store
+	assert.Equal([]int64{sub.TransactionID, renewed1.TransactionID, renewed2.TransactionID}, r.LatestReceiptInfo.TransactionIDs())
+
+	ids := []int64{sub.TransactionID, renewed1.TransactionID, renewed2.TransactionID}
+	assert.Equal(renewed2.TransactionID, r.LatestReceiptInfo.LastExpiresByProductIDForLatest("monthly").TransactionID)
+	assert.Equal(renewed2.TransactionID, r.LatestReceiptInfo.LastExpiresByTransactionIDsForLatest(ids).TransactionID)
+	assert.Equal(r.LatestReceiptInfo.LastExpiresByProductID("monthly"), r.LatestReceiptInfo.LastExpiresByProductIDForLatest("monthly"))
+}
+
+func TestServerRefundAndBillingRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	receiptData := s.AddUser("user1", Sandbox)
+
+	premium, _ := s.Purchase("user1", "premium")
+	sub, _ := s.PurchaseTrial("user1", "monthly")
+	assert.NoError(s.Refund(premium.TransactionID))
+	assert.NoError(s.StartBillingRetry(sub.OriginalTransactionID))
+	assert.Equal(ErrNotSubscription, s.StartBillingRetry(premium.TransactionID))
+	assert.Equal(ErrTransactionNotFound, s.Refund(1))
+
+	r, err := testVerify(s.SandboxURL(), receiptData)
+	assert.NoError(err)
+	assert.Equal(Sandbox, r.Environment)
+	assert.Equal("ProductionSandbox", r.ReceiptType)
+	assert.True(r.InApps[0].CancellationDate.Equal(s.Now()))
+	assert.True(r.InApps[1].IsTrialPeriod)
+	info := r.PendingRenewalInfo[0]
+	assert.True(info.RetryFlag)
+	assert.Equal(int64(2), info.ExpirationIntent)
+
+	// renewal finishes the billing retry
+	s.Advance(31 * 24 * time.Hour)
+	renewed, err := s.Renew(sub.OriginalTransactionID)
+	assert.NoError(err)
+	assert.Equal(s.Now(), renewed.PurchaseDate)
+	assert.NoError(s.SetAutoRenew(sub.OriginalTransactionID, false))
+
+	r, _ = testVerify(s.SandboxURL(), receiptData)
+	info = r.PendingRenewalInfo[0]
+	assert.False(info.RetryFlag)
+	assert.False(info.AutoRenewStatus)
+	assert.Equal(int64(1), info.ExpirationIntent)
+}
+
+func TestServerVerifyReceiptErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	sandbox := s.AddUser("tester", Sandbox)
+	production := s.AddUser("user1", Production)
+
+	tests := []struct {
+		url         string
+		receiptData string
+		status      int
+	}{
+		{s.ProductionURL(), sandbox, 21007},
+		{s.SandboxURL(), production, 21008},
+		{s.ProductionURL(), "unknown", 21002},
+	}
+	for _, v := range tests {
+		r, err := testVerify(v.url, v.receiptData)
+		assert.NoError(err)
+		assert.Equal(v.status, r.Status)
+	}
+
+	s.FailNext(21005, 21100)
+	r, _ := testVerify(s.ProductionURL(), production)
+	assert.Equal(21005, r.Status)
+	r, _ = testVerify(s.ProductionURL(), production)
+	assert.Equal(21100, r.Status)
+	assert.True(r.ShouldRetry())
+	r, _ = testVerify(s.ProductionURL(), production)
+	assert.Equal(0, r.Status)
+
+	s.SetPassword("secret")
+	r, _ = testVerify(s.ProductionURL(), production)
+	assert.Equal(21004, r.Status)
+
+	_, err := s.Purchase("unknown", "coin")
+	assert.Equal(ErrUserNotFound, err)
+	_, err = s.Purchase("user1", "unknown")
+	assert.Equal(ErrProductNotFound, err)
+}
+
+func testServerAPIClient(t *testing.T, s *Server) *appstore.ServerAPIClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := appstore.NewServerAPIClient(appstore.ServerAPIConfig{
+		KeyID:            "KEYID",
+		IssuerID:         "issuer",
+		BundleID:         testBundleID,
+		PrivateKey:       pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		RootCertificates: s.Signer().Roots(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.URL = s.URL
+	return client
+}
+
+func TestServerAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testServer(t)
+	defer s.Close()
+	s.AddUser("user1", Production)
+	coin, _ := s.Purchase("user1", "coin")
+	season, _ := s.Purchase("user1", "season")
+	sub, _ := s.Purchase("user1", "monthly")
+	s.Refund(coin.TransactionID)
+
+	client := testServerAPIClient(t, s)
+
+	txs, err := client.LookUpOrderID(season.OrderID)
+	assert.NoError(err)
+	assert.Len(txs, 1)
+	assert.Equal(season.TransactionID, txs[0].TransactionID)
+	assert.True(season.ExpiresDate.Equal(txs[0].ExpiresDate))
+	_, err = client.LookUpOrderID("unknown")
+	assert.Equal(appstore.ErrInvalidOrderID, err)
+
+	txs, err = client.GetRefundHistory(strconv.FormatInt(sub.TransactionID, 10))
+	assert.NoError(err)
+	assert.Len(txs, 1)
+	assert.Equal(coin.TransactionID, txs[0].TransactionID)
+	assert.True(s.Now().Equal(txs[0].CancellationDate))
+	_, err = client.GetRefundHistory("1")
+	assert.Equal(4040010, int(err.(*appstore.ServerAPIError).ErrorCode))
+
+	err = client.SendConsumptionInformation(strconv.FormatInt(coin.TransactionID, 10), appstore.ConsumptionRequest{
+		CustomerConsented: true,
+		DeliveryStatus:    appstore.DeliveryStatusDelivered,
+	})
+	assert.NoError(err)
+
+	resp, err := client.ExtendSubscriptionRenewalDate(strconv.FormatInt(sub.OriginalTransactionID, 10), appstore.ExtendRenewalDateRequest{
+		ExtendByDays:     7,
+		ExtendReasonCode: 1,
+	})
+	assert.NoError(err)
+	assert.True(resp.Success)
+	assert.True(sub.ExpiresDate.AddDate(0, 0, 7).Equal(resp.GetEffectiveDate()))
+	list, _ := s.Transactions("user1")
+	assert.True(resp.GetEffectiveDate().Equal(list[2].ExpiresDate))
+}
@@ -0,0 +1,123 @@
+package appstoretest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
+// the extensions which the App Store requires in the intermediate and leaf certificates
+var (
+	oidAppleWWDRIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+	oidAppleStoreKitLeaf     = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+)
+
+// Signer signs JWS payloads as the App Store does, with its own root certificate.
+// Pass Roots to RootCertificates of appstore.ServerAPIConfig or the parse functions to verify the payloads.
+type Signer struct {
+	key   *ecdsa.PrivateKey
+	x5c   []string
+	roots *x509.CertPool
+}
+
+// NewSigner creates Signer with the new root, intermediate and leaf certificates
+func NewSigner() (*Signer, error) {
+	now := time.Now()
+	issue := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		template.NotBefore = now.Add(-time.Hour)
+		template.NotAfter = now.AddDate(10, 0, 0)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		return cert, key, err
+	}
+
+	root, rootKey, err := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "appstoretest Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, intermediateKey, err := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "appstoretest Intermediate CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAppleWWDRIntermediate, Value: asn1.NullBytes}},
+	}, root, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, leafKey, err := issue(&x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "appstoretest Leaf"},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleStoreKitLeaf, Value: asn1.NullBytes}},
+	}, intermediate, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &Signer{
+		key: leafKey,
+		x5c: []string{
+			base64.StdEncoding.EncodeToString(leaf.Raw),
+			base64.StdEncoding.EncodeToString(intermediate.Raw),
+			base64.StdEncoding.EncodeToString(root.Raw),
+		},
+		roots: roots,
+	}, nil
+}
+
+// Roots returns the root certificate of the signed payloads
+func (s *Signer) Roots() *x509.CertPool {
+	return s.roots
+}
+
+// Sign encodes the payload into JSON and signs it with ES256
+func (s *Signer) Sign(payload interface{}) (string, error) {
+	header, err := json.Marshal(map[string]interface{}{"alg": "ES256", "x5c": s.x5c})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	hash := sha256.Sum256([]byte(unsigned))
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), ss.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,324 @@
+package appstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchWorkers       = 10
+	defaultBatchRetryInterval = time.Second
+)
+
+// ErrBatchStopped is set to the request interrupted by Batch.Stop, and the result is not yielded
+var ErrBatchStopped = errors.New("The batch is stopped.")
+
+// BatchConfig is a configuration of VerifyBatch
+type BatchConfig struct {
+	// Workers is the number of concurrent verifications (default: 10)
+	Workers int
+	// RateLimit is the maximum number of requests per second including retries.
+	// No limit when it's zero.
+	RateLimit float64
+	// Burst is the number of requests allowed to exceed RateLimit at once (default: 1)
+	Burst int
+	// MaxRetry is the maximum number of retries for network errors, 5xx responses and retryable statuses
+	MaxRetry int
+	// RetryInterval is the wait before the first retry, and it's doubled for each retry (default: 1s)
+	RetryInterval time.Duration
+	// Ordered yields the results in the order of the requests.
+	// Otherwise the results are yielded as they complete.
+	Ordered bool
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.Workers <= 0 {
+		c.Workers = defaultBatchWorkers
+	}
+	if c.Burst <= 0 {
+		c.Burst = 1
+	}
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = defaultBatchRetryInterval
+	}
+	return c
+}
+
+// BatchResult is the result of each request in VerifyBatch
+type BatchResult struct {
+	// Index is the position of the request in the stream
+	Index   int
+	Request IAPRequest
+	Receipt *Receipt
+	// Err is the network error or the error of the receipt status
+	Err      error
+	Attempts int
+}
+
+// BatchStats is the aggregate stats of VerifyBatch
+type BatchStats struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	Retries   int
+	Duration  time.Duration
+}
+
+// Batch is the running VerifyBatch
+type Batch struct {
+	results chan BatchResult
+	limiter *tokenBucket
+	config  BatchConfig
+	started time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu    sync.Mutex
+	stats BatchStats
+}
+
+type batchJob struct {
+	index   int
+	request IAPRequest
+}
+
+// VerifyBatch verifies the stream of the requests on the bounded worker pool.
+// The requests channel should be closed by the caller after the last request,
+// and the results channel is closed after the results of all requests are yielded.
+// Stop should be called when the caller stops reading the results before that.
+func VerifyBatch(client IAPClient, requests <-chan IAPRequest, config BatchConfig) *Batch {
+	config = config.withDefaults()
+	b := &Batch{
+		results: make(chan BatchResult, config.Workers),
+		limiter: newTokenBucket(config.RateLimit, config.Burst),
+		config:  config,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+	}
+
+	jobs := make(chan batchJob)
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			var req IAPRequest
+			var ok bool
+			select {
+			case req, ok = <-requests:
+			case <-b.stop:
+				return
+			}
+			if !ok {
+				return
+			}
+
+			select {
+			case jobs <- batchJob{index: i, request: req}:
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+
+	completed := make(chan BatchResult, config.Workers)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case completed <- b.verify(client, job):
+				case <-b.stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(completed)
+	}()
+
+	go b.collect(completed)
+	return b
+}
+
+// Results returns the channel of the results
+func (b *Batch) Results() <-chan BatchResult {
+	return b.results
+}
+
+// Stats returns the stats of the yielded results
+func (b *Batch) Stats() BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Stop stops reading the requests and retrying, and discards the results not yielded yet.
+// The discarded results are not counted in Stats.
+// The results channel is closed after the running requests are finished.
+func (b *Batch) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+}
+
+func (b *Batch) isStopped() bool {
+	select {
+	case <-b.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait discards the rest of the results and returns the final stats
+func (b *Batch) Wait() BatchStats {
+	for range b.results {
+	}
+	return b.Stats()
+}
+
+// verify sends the request and retries it for network errors, 5xx responses and retryable statuses
+func (b *Batch) verify(client IAPClient, job batchJob) BatchResult {
+	result := BatchResult{
+		Index:   job.index,
+		Request: job.request,
+	}
+	interval := b.config.RetryInterval
+	for {
+		if !b.limiter.wait(b.stop) {
+			result.Err = ErrBatchStopped
+			return result
+		}
+		result.Attempts++
+		result.Receipt, result.Err = client.Verify(job.request)
+		if !isRetryable(result.Receipt, result.Err) || result.Attempts > b.config.MaxRetry {
+			break
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-b.stop:
+			timer.Stop()
+			result.Err = ErrBatchStopped
+			return result
+		}
+		interval *= 2
+	}
+
+	if result.Err == nil {
+		result.Err = result.Receipt.HasError()
+	}
+	return result
+}
+
+// isRetryable checks the result of Verify may succeed by retrying it.
+// Only the transient errors are retried, and ErrCircuitOpen is returned immediately to fail fast during the outage.
+func isRetryable(receipt *Receipt, err error) bool {
+	if err == nil {
+		return receipt.ShouldRetry()
+	}
+	return err != ErrCircuitOpen && isTransientError(err)
+}
+
+// collect yields the completed results and updates the stats
+func (b *Batch) collect(completed <-chan BatchResult) {
+	pending := make(map[int]BatchResult)
+	next := 0
+	for result := range completed {
+		if !b.config.Ordered {
+			b.yield(result)
+			continue
+		}
+
+		// wait for the preceding results
+		pending[result.Index] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			b.yield(r)
+			next++
+		}
+	}
+	close(b.results)
+}
+
+func (b *Batch) yield(result BatchResult) {
+	if result.Err == ErrBatchStopped || b.isStopped() {
+		return
+	}
+
+	b.mu.Lock()
+	b.stats.Total++
+	if result.Err == nil {
+		b.stats.Succeeded++
+	} else {
+		b.stats.Failed++
+	}
+	b.stats.Retries += result.Attempts - 1
+	b.stats.Duration = time.Since(b.started)
+	b.mu.Unlock()
+
+	select {
+	case b.results <- result:
+	case <-b.stop:
+	}
+}
+
+// tokenBucket limits the rate of the requests
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available, and returns false when it's stopped
+func (t *tokenBucket) wait(stop <-chan struct{}) bool {
+	if t.rate <= 0 {
+		return true
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+
+	// reserve the token, and wait until it's filled
+	t.tokens--
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
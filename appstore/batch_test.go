@@ -0,0 +1,263 @@
+package appstore
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBatchClient returns the receipt with the status in ReceiptData
+type testBatchClient struct {
+	mu            sync.Mutex
+	calls         map[string]int
+	running       int
+	maxRunning    int
+	delay         func(req IAPRequest) time.Duration
+	failures      int
+	networkErrors int
+	// err is returned for every call when it's set
+	err error
+}
+
+func (c *testBatchClient) Verify(req IAPRequest) (*Receipt, error) {
+	c.mu.Lock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[req.ReceiptData]++
+	call := c.calls[req.ReceiptData]
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+	if c.delay != nil {
+		time.Sleep(c.delay(req))
+	}
+
+	switch {
+	case c.err != nil:
+		return nil, c.err
+	case call <= c.networkErrors:
+		return nil, errors.New("network error")
+	case call <= c.failures:
+		return &Receipt{Status: 21100, IsRetryable: true}, nil
+	}
+	status, _ := strconv.Atoi(req.ReceiptData)
+	return &Receipt{Status: status}, nil
+}
+
+func testBatchRequests(statuses ...int) <-chan IAPRequest {
+	ch := make(chan IAPRequest, len(statuses))
+	for _, s := range statuses {
+		ch <- IAPRequest{ReceiptData: strconv.Itoa(s)}
+	}
+	close(ch)
+	return ch
+}
+
+func TestVerifyBatchOrdered(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &testBatchClient{
+		// the first requests complete later
+		delay: func(req IAPRequest) time.Duration {
+			if req.ReceiptData == "0" {
+				return 20 * time.Millisecond
+			}
+			return 0
+		},
+	}
+	b := VerifyBatch(client, testBatchRequests(0, 0, 21002, 21006, 0), BatchConfig{
+		Workers: 3,
+		Ordered: true,
+	})
+
+	var results []BatchResult
+	for r := range b.Results() {
+		results = append(results, r)
+	}
+	assert.Len(results, 5)
+	for i, r := range results {
+		assert.Equal(i, r.Index)
+		assert.Equal(1, r.Attempts)
+	}
+	assert.NoError(results[0].Err)
+	assert.Error(results[2].Err)
+	assert.Equal(21002, results[2].Receipt.Status)
+	assert.NoError(results[3].Err)
+	assert.True(client.maxRunning <= 3)
+
+	stats := b.Stats()
+	assert.Equal(5, stats.Total)
+	assert.Equal(4, stats.Succeeded)
+	assert.Equal(1, stats.Failed)
+	assert.Equal(0, stats.Retries)
+	assert.True(stats.Duration > 0)
+}
+
+func TestVerifyBatchUnordered(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &testBatchClient{
+		delay: func(req IAPRequest) time.Duration {
+			if req.ReceiptData == "0" {
+				return 50 * time.Millisecond
+			}
+			return 0
+		},
+	}
+	b := VerifyBatch(client, testBatchRequests(0, 21002, 21002, 21002), BatchConfig{Workers: 2})
+
+	var indexes []int
+	for r := range b.Results() {
+		indexes = append(indexes, r.Index)
+	}
+	assert.ElementsMatch([]int{0, 1, 2, 3}, indexes)
+	assert.NotEqual(0, indexes[0])
+}
+
+func TestVerifyBatchRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &testBatchClient{networkErrors: 1, failures: 2}
+	stats := VerifyBatch(client, testBatchRequests(0), BatchConfig{
+		MaxRetry:      2,
+		RetryInterval: time.Millisecond,
+	}).Wait()
+	assert.Equal(BatchStats{Total: 1, Succeeded: 1, Retries: 2, Duration: stats.Duration}, stats)
+	assert.Equal(3, client.calls["0"])
+
+	// retry is exhausted
+	client = &testBatchClient{networkErrors: 1, failures: 5}
+	b := VerifyBatch(client, testBatchRequests(0), BatchConfig{
+		MaxRetry:      2,
+		RetryInterval: time.Millisecond,
+	})
+	r := <-b.Results()
+	assert.Equal(3, r.Attempts)
+	assert.Equal(21100, r.Receipt.Status)
+	assert.Error(r.Err)
+
+	client = &testBatchClient{networkErrors: 5}
+	b = VerifyBatch(client, testBatchRequests(0), BatchConfig{})
+	r = <-b.Results()
+	assert.Equal(1, r.Attempts)
+	assert.Nil(r.Receipt)
+	assert.EqualError(r.Err, "network error")
+}
+
+func TestVerifyBatchNonRetryable(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		err      error
+		attempts int
+	}{
+		{&httpStatusError{StatusCode: 503}, 3},
+		{&httpStatusError{StatusCode: 400}, 1},
+		{&PolicyError{}, 1},
+		{&ReceiptDataError{Reason: ReceiptDataEmpty}, 1},
+		{&json.SyntaxError{}, 1},
+		{ErrCircuitOpen, 1},
+	}
+	for _, tt := range tests {
+		client := &testBatchClient{err: tt.err}
+		r := <-VerifyBatch(client, testBatchRequests(0), BatchConfig{
+			MaxRetry:      2,
+			RetryInterval: time.Millisecond,
+		}).Results()
+		assert.Equal(tt.attempts, r.Attempts, tt.err.Error())
+		assert.Equal(tt.err, r.Err)
+	}
+}
+
+func TestVerifyBatchStop(t *testing.T) {
+	assert := assert.New(t)
+
+	// the requests are never closed, and the retries never end
+	requests := make(chan IAPRequest, 10)
+	for i := 0; i < 10; i++ {
+		requests <- IAPRequest{ReceiptData: strconv.Itoa(i)}
+	}
+	client := &testBatchClient{networkErrors: 100}
+	b := VerifyBatch(client, requests, BatchConfig{
+		Workers:       2,
+		MaxRetry:      100,
+		RetryInterval: time.Hour,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		b.Wait()
+		close(done)
+	}()
+	b.Stop()
+	b.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail("the results are not closed after Stop")
+	}
+}
+
+func TestVerifyBatchStopWithRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	b := VerifyBatch(&testBatchClient{}, testBatchRequests(0, 0, 0, 0, 0), BatchConfig{
+		Workers:   5,
+		RateLimit: 1,
+		Burst:     1,
+	})
+	r := <-b.Results()
+	assert.NoError(r.Err)
+	b.Stop()
+
+	received := 1
+	for r := range b.Results() {
+		assert.NotEqual(ErrBatchStopped, r.Err)
+		received++
+	}
+	stats := b.Stats()
+	assert.Equal(received, stats.Total)
+	assert.Equal(received, stats.Succeeded)
+	assert.Equal(0, stats.Failed)
+	assert.Equal(0, stats.Retries)
+}
+
+func TestVerifyBatchRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Now()
+	stats := VerifyBatch(&testBatchClient{}, testBatchRequests(0, 0, 0, 0, 0, 0), BatchConfig{
+		Workers:   6,
+		RateLimit: 50,
+		Burst:     2,
+	}).Wait()
+	assert.Equal(6, stats.Total)
+	// 2 requests at once, and 4 requests in every 20ms
+	assert.True(time.Since(start) >= 70*time.Millisecond, time.Since(start).String())
+}
+
+func TestReceiptShouldRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False((&Receipt{Status: 0}).ShouldRetry())
+	assert.False((&Receipt{Status: 21002}).ShouldRetry())
+	assert.True((&Receipt{Status: 21002, IsRetryable: true}).ShouldRetry())
+	assert.True((&Receipt{Status: 21005}).ShouldRetry())
+	assert.True((&Receipt{Status: 21100}).ShouldRetry())
+	assert.True((&Receipt{Status: 21199}).ShouldRetry())
+	assert.False((&Receipt{Status: 21200}).ShouldRetry())
+}
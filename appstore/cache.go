@@ -0,0 +1,145 @@
+package appstore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const defaultCacheTTL = time.Hour
+
+// Cache is an interface to store the verified receipts in Client.
+// Client stores and returns the shallow copy of the receipt, so that each caller can call EnableIndex.
+// The slices in the cached receipt are shared between the callers, so they must not be modified.
+type Cache interface {
+	Get(key string) (*Receipt, bool)
+	Set(key string, receipt *Receipt, ttl time.Duration)
+}
+
+// cacheKey returns the hash of the request
+func cacheKey(req IAPRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.ReceiptData))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Password))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// shallowCopy returns the copy of the receipt without the index
+func (r *Receipt) shallowCopy() *Receipt {
+	copied := *r
+	copied.index = nil
+	return &copied
+}
+
+// isCacheable checks the receipt can be cached or not.
+// The receipt with error or retryable status is not cached.
+func isCacheable(r *Receipt) bool {
+	return r.HasError() == nil && !r.ShouldRetry()
+}
+
+// cacheTTL returns the ttl of the receipt bounded by the earliest expiry of the subscriptions after now
+func cacheTTL(r *Receipt, ttl time.Duration, now time.Time) time.Duration {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	for _, list := range []ReceiptInApps{r.InApps, r.LatestReceiptInfo} {
+		for _, v := range list {
+			if !v.ExpiresDate.After(now) {
+				continue
+			}
+			if d := v.ExpiresDate.Sub(now); d < ttl {
+				ttl = d
+			}
+		}
+	}
+	return ttl
+}
+
+// LRUCache is an in-memory Cache which evicts the least recently used receipt
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	receipt  *Receipt
+	expireAt time.Time
+}
+
+// NewLRUCache creates LRUCache which stores the receipts up to the size
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		list:    list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached receipt
+func (c *LRUCache) Get(key string) (*Receipt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !time.Now().Before(entry.expireAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.list.MoveToFront(elem)
+	return entry.receipt, true
+}
+
+// Set stores the receipt until the ttl is passed
+func (c *LRUCache) Set(key string, receipt *Receipt, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expireAt := time.Now().Add(ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.receipt = receipt
+		entry.expireAt = expireAt
+		c.list.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.list.PushFront(&lruEntry{
+		key:      key,
+		receipt:  receipt,
+		expireAt: expireAt,
+	})
+	for c.size > 0 && c.list.Len() > c.size {
+		c.remove(c.list.Back())
+	}
+}
+
+// Delete removes the cached receipt
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of the cached receipts
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.Len()
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	c.list.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry).key)
+}
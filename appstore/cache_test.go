@@ -0,0 +1,139 @@
+package appstore
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewLRUCache(2)
+	r1, r2, r3 := &Receipt{Status: 1}, &Receipt{Status: 2}, &Receipt{Status: 3}
+	c.Set("k1", r1, time.Minute)
+	c.Set("k2", r2, time.Minute)
+
+	r, ok := c.Get("k1")
+	assert.True(ok)
+	assert.Equal(r1, r)
+
+	// k2 is least recently used
+	c.Set("k3", r3, time.Minute)
+	assert.Equal(2, c.Len())
+	_, ok = c.Get("k2")
+	assert.False(ok)
+	_, ok = c.Get("k1")
+	assert.True(ok)
+
+	// update
+	c.Set("k1", r2, time.Minute)
+	r, _ = c.Get("k1")
+	assert.Equal(r2, r)
+	assert.Equal(2, c.Len())
+
+	c.Delete("k1")
+	_, ok = c.Get("k1")
+	assert.False(ok)
+
+	// expired
+	c.Set("k4", r1, -time.Second)
+	_, ok = c.Get("k4")
+	assert.False(ok)
+	assert.Equal(1, c.Len())
+}
+
+func TestCacheKey(t *testing.T) {
+	assert := assert.New(t)
+
+	key := cacheKey(IAPRequest{ReceiptData: "receipt", Password: "secret"})
+	assert.Len(key, 64)
+	assert.Equal(key, cacheKey(IAPRequest{ReceiptData: "receipt", Password: "secret"}))
+	assert.NotEqual(key, cacheKey(IAPRequest{ReceiptData: "receipt", Password: "secret2"}))
+	assert.NotEqual(cacheKey(IAPRequest{ReceiptData: "ab", Password: "c"}), cacheKey(IAPRequest{ReceiptData: "a", Password: "bc"}))
+}
+
+func TestCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Receipt{
+		InApps: ReceiptInApps{
+			{ExpiresDate: now.Add(-time.Hour)},
+			{ExpiresDate: now.Add(30 * time.Minute)},
+			{},
+		},
+		LatestReceiptInfo: ReceiptInApps{
+			{ExpiresDate: now.Add(10 * time.Minute)},
+		},
+	}
+	assert.Equal(10*time.Minute, cacheTTL(r, time.Hour, now))
+	assert.Equal(5*time.Minute, cacheTTL(r, 5*time.Minute, now))
+	assert.Equal(defaultCacheTTL, cacheTTL(&Receipt{}, 0, now))
+
+	assert.True(isCacheable(&Receipt{Status: 0}))
+	assert.True(isCacheable(&Receipt{Status: 21006}))
+	assert.False(isCacheable(&Receipt{Status: 21002}))
+	assert.False(isCacheable(&Receipt{Status: 0, IsRetryable: true}))
+}
+
+func TestVerifyWithCache(t *testing.T) {
+	assert := assert.New(t)
+
+	var count int32
+	body := testReceiptString
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		fmt.Fprintln(w, body)
+	}))
+	defer server.Close()
+
+	client := NewWithConfig(Config{Cache: NewLRUCache(10)})
+	client.URL = server.URL
+	assert.Equal(defaultCacheTTL, client.CacheTTL)
+
+	req := IAPRequest{ReceiptData: "dummy data", Password: "secret"}
+	r1, err := client.Verify(req)
+	assert.NoError(err)
+	r2, err := client.Verify(req)
+	assert.NoError(err)
+	assert.Equal(r1, r2)
+	assert.False(r1 == r2)
+	assert.EqualValues(1, atomic.LoadInt32(&count))
+
+	// each caller can index the cached receipt concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := client.Verify(req)
+			assert.NoError(err)
+			r.EnableIndex()
+			assert.NotEmpty(r.GetTransactionIDs())
+		}()
+	}
+	r1.EnableIndex()
+	r1.GetTransactionIDs()
+	wg.Wait()
+
+	// different password
+	_, err = client.Verify(IAPRequest{ReceiptData: "dummy data", Password: "other"})
+	assert.NoError(err)
+	assert.EqualValues(2, atomic.LoadInt32(&count))
+
+	// error status is not cached
+	body = `{"status": 21100, "is_retryable": true}`
+	req = IAPRequest{ReceiptData: "retryable data"}
+	client.Verify(req)
+	r, err := client.Verify(req)
+	assert.NoError(err)
+	assert.True(r.IsRetryable)
+	assert.EqualValues(4, atomic.LoadInt32(&count))
+}
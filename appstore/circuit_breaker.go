@@ -0,0 +1,221 @@
+package appstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerMinRequests  = 10
+	defaultBreakerWindow       = time.Minute
+	defaultBreakerOpenTimeout  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling App Store while the circuit breaker is open
+var ErrCircuitOpen = errors.New("The circuit breaker is open.")
+
+// CircuitState is the state of CircuitBreaker
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig is a configuration of CircuitBreaker
+type CircuitBreakerConfig struct {
+	// FailureRatio opens the circuit when the ratio of failures in the window reaches it (default: 0.5)
+	FailureRatio float64
+	// MinRequests is the minimum number of requests in the window to open the circuit (default: 10)
+	MinRequests int
+	// Window is the duration to count the requests while the circuit is closed (default: 1m)
+	Window time.Duration
+	// OpenTimeout is the duration to half-open the circuit after it's opened (default: 30s)
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of trial requests while the circuit is half-open (default: 1).
+	// The circuit is closed when all of them succeed.
+	HalfOpenRequests int
+	// OnStateChange is called when the state is changed
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker stops calling App Store during the outage.
+// Network errors and the statuses of App Store server errors (21005, 21100-21199) are counted as failures.
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	trials      int
+	successes   int
+}
+
+// NewCircuitBreaker creates CircuitBreaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureRatio <= 0 {
+		config.FailureRatio = defaultBreakerFailureRatio
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultBreakerMinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = defaultBreakerWindow
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	cb := &CircuitBreaker{
+		config: config,
+		now:    time.Now,
+	}
+	cb.windowStart = cb.now()
+	return cb
+}
+
+// State returns the current state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	changed := cb.update(cb.now())
+	state := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(changed)
+	return state
+}
+
+// allow checks the request can be sent or not, and returns the generation of the state
+func (cb *CircuitBreaker) allow() (uint64, error) {
+	cb.mu.Lock()
+	changed := cb.update(cb.now())
+	generation := cb.generation
+	var err error
+	switch cb.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.trials >= cb.config.HalfOpenRequests {
+			err = ErrCircuitOpen
+			break
+		}
+		cb.trials++
+	default:
+		cb.requests++
+	}
+	cb.mu.Unlock()
+
+	cb.notify(changed)
+	return generation, err
+}
+
+// done records the result of the request allowed in the generation
+func (cb *CircuitBreaker) done(generation uint64, failure bool) {
+	cb.mu.Lock()
+	now := cb.now()
+	changed := cb.update(now)
+	if generation == cb.generation {
+		switch cb.state {
+		case CircuitHalfOpen:
+			switch {
+			case failure:
+				changed = append(changed, cb.setState(CircuitOpen, now)...)
+			default:
+				cb.successes++
+				if cb.successes >= cb.config.HalfOpenRequests {
+					changed = append(changed, cb.setState(CircuitClosed, now)...)
+				}
+			}
+		case CircuitClosed:
+			if failure {
+				cb.failures++
+			}
+			if cb.requests >= cb.config.MinRequests &&
+				float64(cb.failures)/float64(cb.requests) >= cb.config.FailureRatio {
+				changed = append(changed, cb.setState(CircuitOpen, now)...)
+			}
+		}
+	}
+	cb.mu.Unlock()
+
+	cb.notify(changed)
+}
+
+// update changes the state by the time
+func (cb *CircuitBreaker) update(now time.Time) []CircuitState {
+	switch cb.state {
+	case CircuitClosed:
+		if now.Sub(cb.windowStart) >= cb.config.Window {
+			cb.resetCounts(now)
+		}
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) >= cb.config.OpenTimeout {
+			return cb.setState(CircuitHalfOpen, now)
+		}
+	}
+	return nil
+}
+
+// setState changes the state and returns the pair of the states before and after
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) []CircuitState {
+	if cb.state == state {
+		return nil
+	}
+	from := cb.state
+	cb.state = state
+	cb.generation++
+	cb.resetCounts(now)
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+	return []CircuitState{from, state}
+}
+
+func (cb *CircuitBreaker) resetCounts(now time.Time) {
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+	cb.trials = 0
+	cb.successes = 0
+}
+
+// notify calls OnStateChange outside of the lock
+func (cb *CircuitBreaker) notify(changed []CircuitState) {
+	if cb.config.OnStateChange == nil {
+		return
+	}
+	for i := 0; i+1 < len(changed); i += 2 {
+		cb.config.OnStateChange(changed[i], changed[i+1])
+	}
+}
+
+// isBreakerFailure checks the result of verifyReceipt is the failure of App Store or not.
+// The errors caused by the request, such as 4xx status and the malformed response, are not counted.
+func isBreakerFailure(receipt *Receipt, err error) bool {
+	if err != nil {
+		return isTransientError(err)
+	}
+	return receipt.HasServerError()
+}
@@ -0,0 +1,189 @@
+package appstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func testCircuitBreaker(config CircuitBreakerConfig) (*CircuitBreaker, *testClock, *[]string) {
+	var changes []string
+	config.OnStateChange = func(from, to CircuitState) {
+		changes = append(changes, from.String()+"->"+to.String())
+	}
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cb := NewCircuitBreaker(config)
+	cb.now = clock.Now
+	cb.windowStart = clock.now
+	return cb, clock, &changes
+}
+
+func testBreakerCall(cb *CircuitBreaker, failure bool) error {
+	generation, err := cb.allow()
+	if err != nil {
+		return err
+	}
+	cb.done(generation, failure)
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	cb, clock, changes := testCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      4,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenRequests: 2,
+	})
+	assert.Equal(CircuitClosed, cb.State())
+
+	// not enough requests
+	assert.NoError(testBreakerCall(cb, true))
+	assert.NoError(testBreakerCall(cb, true))
+	assert.NoError(testBreakerCall(cb, false))
+	assert.Equal(CircuitClosed, cb.State())
+
+	// 3 failures in 4 requests
+	assert.NoError(testBreakerCall(cb, true))
+	assert.Equal(CircuitOpen, cb.State())
+	assert.Equal(ErrCircuitOpen, testBreakerCall(cb, false))
+
+	// half-open after timeout, and the number of trials is limited
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.Equal(CircuitHalfOpen, cb.State())
+	g1, err := cb.allow()
+	assert.NoError(err)
+	g2, err := cb.allow()
+	assert.NoError(err)
+	_, err = cb.allow()
+	assert.Equal(ErrCircuitOpen, err)
+
+	// failure in half-open opens again
+	cb.done(g1, true)
+	assert.Equal(CircuitOpen, cb.State())
+	// stale result is ignored
+	cb.done(g2, false)
+	assert.Equal(CircuitOpen, cb.State())
+
+	// all trials succeed
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.NoError(testBreakerCall(cb, false))
+	assert.Equal(CircuitHalfOpen, cb.State())
+	assert.NoError(testBreakerCall(cb, false))
+	assert.Equal(CircuitClosed, cb.State())
+
+	assert.Equal([]string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, *changes)
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	cb, clock, _ := testCircuitBreaker(CircuitBreakerConfig{
+		MinRequests: 2,
+		Window:      time.Minute,
+	})
+	assert.NoError(testBreakerCall(cb, true))
+
+	// counts are reset in the next window
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(testBreakerCall(cb, false))
+	assert.NoError(testBreakerCall(cb, false))
+	assert.NoError(testBreakerCall(cb, true))
+	assert.Equal(CircuitClosed, cb.State())
+}
+
+func TestIsBreakerFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isBreakerFailure(nil, errors.New("network error")))
+	assert.True(isBreakerFailure(nil, &httpStatusError{StatusCode: 503}))
+	assert.False(isBreakerFailure(nil, &httpStatusError{StatusCode: 400}))
+	assert.False(isBreakerFailure(nil, &json.SyntaxError{}))
+	assert.False(isBreakerFailure(nil, &ReceiptDataError{Reason: ReceiptDataEmpty}))
+	assert.True(isBreakerFailure(&Receipt{Status: 21005}, nil))
+	assert.True(isBreakerFailure(&Receipt{Status: 21150}, nil))
+	assert.False(isBreakerFailure(&Receipt{Status: 0}, nil))
+	assert.False(isBreakerFailure(&Receipt{Status: 21002}, nil))
+}
+
+func TestVerifyWithCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		fmt.Fprintln(w, `{"status": 21005}`)
+	}))
+	defer server.Close()
+
+	cb := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 2})
+	client := NewWithConfig(Config{CircuitBreaker: cb})
+	client.URL = server.URL
+
+	for i := 0; i < 2; i++ {
+		r, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+		assert.NoError(err)
+		assert.Equal(21005, r.Status)
+	}
+	assert.Equal(CircuitOpen, cb.State())
+
+	_, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+	assert.Equal(ErrCircuitOpen, err)
+	assert.EqualValues(2, atomic.LoadInt32(&count))
+}
+
+func TestVerifyWithCircuitBreakerClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var status int32 = http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := atomic.LoadInt32(&status); s != http.StatusOK {
+			w.WriteHeader(int(s))
+			return
+		}
+		fmt.Fprintln(w, `not json`)
+	}))
+	defer server.Close()
+
+	cb := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 2})
+	client := NewWithConfig(Config{CircuitBreaker: cb})
+	client.URL = server.URL
+
+	// 4xx status
+	for i := 0; i < 3; i++ {
+		_, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+		assert.IsType(&httpStatusError{}, err)
+	}
+	assert.Equal(CircuitClosed, cb.State())
+
+	// malformed response
+	atomic.StoreInt32(&status, http.StatusOK)
+	for i := 0; i < 3; i++ {
+		_, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+		assert.Error(err)
+		assert.NotEqual(ErrCircuitOpen, err)
+	}
+	assert.Equal(CircuitClosed, cb.State())
+}
@@ -0,0 +1,149 @@
+package appstore
+
+import (
+	"errors"
+	"net/url"
+)
+
+type (
+	// ConsumptionStatus indicates the extent to which the customer consumed the in-app purchase
+	ConsumptionStatus int32
+	// Platform indicates the platform on which the customer consumed the in-app purchase
+	Platform int32
+	// DeliveryStatus indicates whether the app successfully delivered the in-app purchase
+	DeliveryStatus int32
+	// AccountTenure indicates the age of the customer's account
+	AccountTenure int32
+	// PlayTime indicates the amount of time that the customer used the app
+	PlayTime int32
+	// LifetimeDollars indicates the total amount of purchases or refunds in USD
+	LifetimeDollars int32
+	// UserStatus indicates the status of the customer's account
+	UserStatus int32
+	// RefundPreference indicates your preference for the outcome of the refund request
+	RefundPreference int32
+)
+
+const (
+	ConsumptionStatusUndeclared ConsumptionStatus = iota
+	ConsumptionStatusNotConsumed
+	ConsumptionStatusPartiallyConsumed
+	ConsumptionStatusFullyConsumed
+)
+
+const (
+	PlatformUndeclared Platform = iota
+	PlatformApple
+	PlatformNonApple
+)
+
+const (
+	DeliveryStatusDelivered DeliveryStatus = iota
+	DeliveryStatusQualityIssue
+	DeliveryStatusWrongItem
+	DeliveryStatusServerOutage
+	DeliveryStatusCurrencyChange
+	DeliveryStatusOtherReason
+)
+
+const (
+	AccountTenureUndeclared AccountTenure = iota
+	AccountTenure0To3Days
+	AccountTenure3To10Days
+	AccountTenure10To30Days
+	AccountTenure30To90Days
+	AccountTenure90To180Days
+	AccountTenure180To365Days
+	AccountTenureOver365Days
+)
+
+const (
+	PlayTimeUndeclared PlayTime = iota
+	PlayTime0To5Minutes
+	PlayTime5To60Minutes
+	PlayTime1To6Hours
+	PlayTime6To24Hours
+	PlayTime1To4Days
+	PlayTime4To16Days
+	PlayTimeOver16Days
+)
+
+const (
+	LifetimeDollarsUndeclared LifetimeDollars = iota
+	LifetimeDollars0
+	LifetimeDollars0To50
+	LifetimeDollars50To100
+	LifetimeDollars100To500
+	LifetimeDollars500To1000
+	LifetimeDollars1000To2000
+	LifetimeDollarsOver2000
+)
+
+const (
+	UserStatusUndeclared UserStatus = iota
+	UserStatusActive
+	UserStatusSuspended
+	UserStatusTerminated
+	UserStatusLimitedAccess
+)
+
+const (
+	RefundPreferenceUndeclared RefundPreference = iota
+	RefundPreferenceGrant
+	RefundPreferenceDecline
+	RefundPreferenceNoPreference
+)
+
+// ConsumptionRequest is the request body of consumption information
+// see: https://developer.apple.com/documentation/appstoreserverapi/consumptionrequest
+type ConsumptionRequest struct {
+	CustomerConsented        bool              `json:"customerConsented"`
+	ConsumptionStatus        ConsumptionStatus `json:"consumptionStatus"`
+	Platform                 Platform          `json:"platform"`
+	SampleContentProvided    bool              `json:"sampleContentProvided"`
+	DeliveryStatus           DeliveryStatus    `json:"deliveryStatus"`
+	AppAccountToken          string            `json:"appAccountToken"`
+	AccountTenure            AccountTenure     `json:"accountTenure"`
+	PlayTime                 PlayTime          `json:"playTime"`
+	LifetimeDollarsRefunded  LifetimeDollars   `json:"lifetimeDollarsRefunded"`
+	LifetimeDollarsPurchased LifetimeDollars   `json:"lifetimeDollarsPurchased"`
+	UserStatus               UserStatus        `json:"userStatus"`
+	RefundPreference         RefundPreference  `json:"refundPreference"`
+}
+
+// Validate checks all of the fields have the values allowed by the App Store
+func (r ConsumptionRequest) Validate() error {
+	switch {
+	case !r.CustomerConsented:
+		return errors.New("customerConsented must be true.")
+	case r.ConsumptionStatus < ConsumptionStatusUndeclared || r.ConsumptionStatus > ConsumptionStatusFullyConsumed:
+		return errors.New("consumptionStatus is invalid.")
+	case r.Platform < PlatformUndeclared || r.Platform > PlatformNonApple:
+		return errors.New("platform is invalid.")
+	case r.DeliveryStatus < DeliveryStatusDelivered || r.DeliveryStatus > DeliveryStatusOtherReason:
+		return errors.New("deliveryStatus is invalid.")
+	case r.AccountTenure < AccountTenureUndeclared || r.AccountTenure > AccountTenureOver365Days:
+		return errors.New("accountTenure is invalid.")
+	case r.PlayTime < PlayTimeUndeclared || r.PlayTime > PlayTimeOver16Days:
+		return errors.New("playTime is invalid.")
+	case r.LifetimeDollarsRefunded < LifetimeDollarsUndeclared || r.LifetimeDollarsRefunded > LifetimeDollarsOver2000:
+		return errors.New("lifetimeDollarsRefunded is invalid.")
+	case r.LifetimeDollarsPurchased < LifetimeDollarsUndeclared || r.LifetimeDollarsPurchased > LifetimeDollarsOver2000:
+		return errors.New("lifetimeDollarsPurchased is invalid.")
+	case r.UserStatus < UserStatusUndeclared || r.UserStatus > UserStatusLimitedAccess:
+		return errors.New("userStatus is invalid.")
+	case r.RefundPreference < RefundPreferenceUndeclared || r.RefundPreference > RefundPreferenceNoPreference:
+		return errors.New("refundPreference is invalid.")
+	}
+	return nil
+}
+
+// SendConsumptionInformation sends consumption information of the transaction
+// in response to CONSUMPTION_REQUEST notification
+// see: https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
+func (c *ServerAPIClient) SendConsumptionInformation(transactionID string, req ConsumptionRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return c.do(put, "/inApps/v1/transactions/consumption/"+url.PathEscape(transactionID), req, nil)
+}
@@ -0,0 +1,72 @@
+package appstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumptionRequestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := ConsumptionRequest{
+		CustomerConsented:        true,
+		ConsumptionStatus:        ConsumptionStatusPartiallyConsumed,
+		Platform:                 PlatformApple,
+		DeliveryStatus:           DeliveryStatusDelivered,
+		AccountTenure:            AccountTenureOver365Days,
+		PlayTime:                 PlayTime1To6Hours,
+		LifetimeDollarsRefunded:  LifetimeDollars0,
+		LifetimeDollarsPurchased: LifetimeDollars50To100,
+		UserStatus:               UserStatusActive,
+		RefundPreference:         RefundPreferenceDecline,
+	}
+	assert.NoError(valid.Validate())
+
+	tests := []func(r *ConsumptionRequest){
+		func(r *ConsumptionRequest) { r.CustomerConsented = false },
+		func(r *ConsumptionRequest) { r.ConsumptionStatus = 4 },
+		func(r *ConsumptionRequest) { r.Platform = -1 },
+		func(r *ConsumptionRequest) { r.DeliveryStatus = 6 },
+		func(r *ConsumptionRequest) { r.AccountTenure = 8 },
+		func(r *ConsumptionRequest) { r.PlayTime = 8 },
+		func(r *ConsumptionRequest) { r.LifetimeDollarsRefunded = 8 },
+		func(r *ConsumptionRequest) { r.LifetimeDollarsPurchased = 8 },
+		func(r *ConsumptionRequest) { r.UserStatus = 5 },
+		func(r *ConsumptionRequest) { r.RefundPreference = 4 },
+	}
+	for i, tt := range tests {
+		r := valid
+		tt(&r)
+		assert.Error(r.Validate(), "case %d", i)
+	}
+}
+
+func TestSendConsumptionInformation(t *testing.T) {
+	assert := assert.New(t)
+
+	var actual ConsumptionRequest
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("PUT", r.Method)
+		assert.Equal("/inApps/v1/transactions/consumption/2000000001", r.URL.Path)
+		assert.NoError(json.NewDecoder(r.Body).Decode(&actual))
+		w.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+
+	req := ConsumptionRequest{
+		CustomerConsented: true,
+		ConsumptionStatus: ConsumptionStatusFullyConsumed,
+		Platform:          PlatformApple,
+		PlayTime:          PlayTime5To60Minutes,
+	}
+	assert.NoError(client.SendConsumptionInformation("2000000001", req))
+	assert.Equal(req, actual)
+
+	// invalid request is not sent
+	actual = ConsumptionRequest{}
+	assert.Error(client.SendConsumptionInformation("2000000001", ConsumptionRequest{}))
+	assert.Equal(ConsumptionRequest{}, actual)
+}
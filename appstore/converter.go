@@ -23,6 +23,7 @@ func ToReceiptInApp(ap InApp) *ReceiptInApp {
 		AppItemID:                 ToInt64(ap.AppItemID),
 		VersionExternalIdentifier: ToInt64(ap.VersionExternalIdentifier),
 		WebOrderLineItemID:        ToInt64(ap.WebOrderLineItemID),
+		InAppOwnershipType:        ap.InAppOwnershipType,
 		PurchaseDate:              ToTime(ap.PurchaseDate.PurchaseDateMS),
 		OriginalPurchaseDate:      ToTime(ap.OriginalPurchaseDate.OriginalPurchaseDateMS),
 		ExpiresDate:               ToTime(ap.ExpiresDate.ExpiresDateMS),
@@ -54,6 +55,14 @@ func ToTime(msString string) time.Time {
 	return time.Unix(int64(ms/1000), 0)
 }
 
+// msToTime converts milliseconds since epoch into time.Time in the same precision as ToTime
+func msToTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, 0)
+}
+
 func ToReceiptPendingRenewalInfos(pris []PendingRenewalInfo) ReceiptPendingRenewalInfos {
 	var rpris ReceiptPendingRenewalInfos
 	for _, pri := range pris {
@@ -0,0 +1,165 @@
+package appstore
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testDecoderFixtures = map[string]string{
+	"ios7":   testReceiptString,
+	"ios7_2": testReceiptString2,
+	"ios7_3": testReceiptString3,
+	"ios6_1": testReceiptStringIOS6_1,
+	"ios6_2": testReceiptStringIOS6_2,
+	"ios6_3": testReceiptStringIOS6_3,
+}
+
+// testIAPResponse is the response in either format for decodeIAPResponseOnePass.
+// `receipt` and `latest_receipt_info` are kept raw until the format is detected by `environment`.
+type testIAPResponse struct {
+	IAPResponseIOS7
+	Receipt           json.RawMessage `json:"receipt"`
+	LatestReceiptInfo json.RawMessage `json:"latest_receipt_info"`
+
+	// iOS 6 style only
+	LatestExpiredReceiptInfo ReceiptIOS6 `json:"latest_expired_receipt_info"`
+	AutoRenewStatus          int         `json:"auto_renew_status"`
+	AutoRenewProductID       string      `json:"auto_renew_product_id"`
+	ExpirationIntent         string      `json:"expiration_intent"`
+	RetryFlag                string      `json:"is_in_billing_retry_period"`
+}
+
+// decodeIAPResponseOnePass decodes the response from the body in one pass to compare with decodeIAPResponse
+func decodeIAPResponseOnePass(body io.Reader, rawReceipt string) (*Receipt, error) {
+	var resp testIAPResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Environment != "" {
+		result := resp.IAPResponseIOS7
+		result.rawReceipt = rawReceipt
+		if err := testUnmarshalRaw(resp.Receipt, &result.Receipt); err != nil {
+			return nil, err
+		}
+		if err := testUnmarshalRaw(resp.LatestReceiptInfo, &result.LatestReceiptInfo); err != nil {
+			return nil, err
+		}
+		return result.ToReceipt(), nil
+	}
+
+	result := IAPResponseIOS6{
+		rawReceipt:               rawReceipt,
+		Status:                   resp.Status,
+		LatestExpiredReceiptInfo: resp.LatestExpiredReceiptInfo,
+		LatestReceipt:            resp.LatestReceipt,
+		AutoRenewStatus:          resp.AutoRenewStatus,
+		AutoRenewProductID:       resp.AutoRenewProductID,
+		ExpirationIntent:         resp.ExpirationIntent,
+		RetryFlag:                resp.RetryFlag,
+		IsRetryable:              resp.IsRetryable,
+	}
+	if err := testUnmarshalRaw(resp.Receipt, &result.Receipt); err != nil {
+		return nil, err
+	}
+	if err := testUnmarshalRaw(resp.LatestReceiptInfo, &result.LatestReceiptInfo); err != nil {
+		return nil, err
+	}
+	return result.ToIOS7().ToReceipt(), nil
+}
+
+func testUnmarshalRaw(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// testDecodeIAPResponse reads the body as verify does and decodes it
+func testDecodeIAPResponse(body io.Reader, rawReceipt string) (*Receipt, error) {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeIAPResponse(b, rawReceipt)
+}
+
+func TestDecodeIAPResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, body := range testDecoderFixtures {
+		r, err := decodeIAPResponse([]byte(body), "raw")
+		assert.NoError(err, name)
+		assert.Equal("raw", r.rawReceipt, name)
+
+		onePass, err := decodeIAPResponseOnePass(strings.NewReader(body), "raw")
+		assert.NoError(err, name)
+		assert.Equal(r, onePass, name)
+	}
+
+	r, err := decodeIAPResponse([]byte(testReceiptString), "raw")
+	assert.NoError(err)
+	assert.Equal(verIOS7, r.ResponseVersion())
+
+	r, err = decodeIAPResponse([]byte(testReceiptStringIOS6_1), "raw")
+	assert.NoError(err)
+	assert.Equal(verIOS6, r.ResponseVersion())
+
+	r, err = decodeIAPResponse([]byte(`{"status": 21002}`), "")
+	assert.NoError(err)
+	assert.Equal(21002, r.Status)
+}
+
+func TestDecodeIAPResponseErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []string{
+		``,
+		`dummy response`,
+		`[]`,
+		`{"status": "0"}`,
+		`{"status": 0, "receipt": []}`,
+		`{"status": 0`,
+	}
+	for _, tt := range tests {
+		_, err := decodeIAPResponse([]byte(tt), "")
+		assert.Error(err, tt)
+		_, err = decodeIAPResponseOnePass(strings.NewReader(tt), "")
+		assert.Error(err, tt)
+	}
+
+	// environment after the receipt
+	r, err := decodeIAPResponse([]byte(`{"receipt": {"bundle_id": "com.example.app"}, "latest_receipt_info": [{"transaction_id": "1"}], "environment": "Sandbox", "unknown": [1, {}]}`), "")
+	assert.NoError(err)
+	assert.Equal(verIOS7, r.ResponseVersion())
+	assert.Equal("com.example.app", r.BundleID)
+	assert.Equal(int64(1), r.LatestReceiptInfo[0].TransactionID)
+}
+
+// BenchmarkDecodeIAPResponse compares decodeIAPResponse ("twice") with decoding in one pass ("once")
+func BenchmarkDecodeIAPResponse(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		decode func(io.Reader, string) (*Receipt, error)
+	}{
+		{"twice", testDecodeIAPResponse},
+		{"once", decodeIAPResponseOnePass},
+	}
+	for name, body := range testDecoderFixtures {
+		for _, bm := range benchmarks {
+			b.Run(name+"/"+bm.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := bm.decode(strings.NewReader(body), ""); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
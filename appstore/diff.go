@@ -0,0 +1,212 @@
+package appstore
+
+import (
+	"sort"
+)
+
+// ReceiptEvent is the change between the receipts in the same type as App Store Server Notifications V2
+type ReceiptEvent struct {
+	Type                  NotificationTypeV2
+	Subtype               NotificationSubtypeV2
+	OriginalTransactionID int64
+	ProductID             string
+	// InApp is the transaction of the event, and nil for the changes of pending_renewal_info
+	InApp *ReceiptInApp
+	// RenewalInfo is the pending_renewal_info of the event, and nil for the changes of transactions
+	RenewalInfo *ReceiptPendingRenewalInfo
+}
+
+// Diff compares the receipts of the same user and returns the events from prev to next.
+// prev can be nil for the first verification, and nil next is treated as the empty receipt.
+//
+// The events of the transactions are ordered by purchase date,
+// and followed by the events of pending_renewal_info:
+//   - ONE_TIME_CHARGE for the new purchase of the product other than subscriptions
+//   - SUBSCRIBED (INITIAL_BUY, RESUBSCRIBE) for the new subscription
+//   - DID_RENEW (empty, BILLING_RECOVERY) for the renewal
+//   - DID_CHANGE_RENEWAL_PREF (UPGRADE) for the upgrade which takes effect immediately
+//   - REFUND and REFUND_REVERSED for cancellation_date
+//   - DID_CHANGE_RENEWAL_STATUS (AUTO_RENEW_ENABLED, AUTO_RENEW_DISABLED) for auto_renew_status
+//   - DID_CHANGE_RENEWAL_PREF (DOWNGRADE, empty) for auto_renew_product_id
+//   - DID_FAIL_TO_RENEW for entering the billing retry period
+func Diff(prev, next *Receipt) []ReceiptEvent {
+	if prev == nil {
+		prev = &Receipt{}
+	}
+	if next == nil {
+		next = &Receipt{}
+	}
+
+	before := make(map[int64]*ReceiptInApp)
+	for _, v := range prev.allTransactions() {
+		before[v.TransactionID] = v
+	}
+
+	var events []ReceiptEvent
+	txs := next.allTransactions()
+	for i, v := range txs {
+		old, ok := before[v.TransactionID]
+		if !ok {
+			events = append(events, purchaseEvent(v, previousInChain(txs, i), prev.PendingRenewalInfo))
+			old = &ReceiptInApp{}
+		}
+
+		switch {
+		case old.CancellationDate.IsZero() && !v.CancellationDate.IsZero() && !isUpgradedIn(txs, v):
+			events = append(events, transactionEvent(NotificationTypeV2Refund, "", v))
+		case !old.CancellationDate.IsZero() && v.CancellationDate.IsZero():
+			events = append(events, transactionEvent(NotificationTypeV2RefundReversed, "", v))
+		}
+	}
+
+	prevTxs := prev.allTransactions()
+	for _, v := range next.PendingRenewalInfo {
+		old := previousRenewalInfo(prev.PendingRenewalInfo, prevTxs, txs, v)
+		if old == nil {
+			continue
+		}
+		events = append(events, next.renewalInfoEvents(old, v)...)
+	}
+	return events
+}
+
+// previousRenewalInfo returns pending_renewal_info of the same subscription before the change.
+// It's matched by product_id, and through the transaction chain by original_transaction_id
+// when product_id is changed by the upgrade.
+func previousRenewalInfo(infos ReceiptPendingRenewalInfos, prevTxs, txs ReceiptInApps, info *ReceiptPendingRenewalInfo) *ReceiptPendingRenewalInfo {
+	if old := infos.byProductID(info.ProductID); old != nil {
+		return old
+	}
+
+	chains := make(map[int64]bool)
+	for _, v := range txs {
+		if v.ProductID == info.ProductID {
+			chains[v.OriginalTransactionID] = true
+		}
+	}
+	// the latest product of the chain in prev
+	for i := len(prevTxs) - 1; i >= 0; i-- {
+		v := prevTxs[i]
+		if !chains[v.OriginalTransactionID] {
+			continue
+		}
+		if old := infos.byProductID(v.ProductID); old != nil {
+			return old
+		}
+	}
+	return nil
+}
+
+// allTransactions returns the transactions in `in_app` and `latest_receipt_info` ordered by purchase date
+func (r *Receipt) allTransactions() ReceiptInApps {
+	seen := make(map[int64]bool)
+	var list ReceiptInApps
+	for _, rc := range []ReceiptInApps{r.InApps, r.LatestReceiptInfo} {
+		for _, v := range rc {
+			if seen[v.TransactionID] {
+				continue
+			}
+			seen[v.TransactionID] = true
+			list = append(list, v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].PurchaseDate.Equal(list[j].PurchaseDate) {
+			return list[i].PurchaseDate.Before(list[j].PurchaseDate)
+		}
+		return list[i].TransactionID < list[j].TransactionID
+	})
+	return list
+}
+
+// previousInChain returns the previous transaction of the same subscription
+func previousInChain(txs ReceiptInApps, i int) *ReceiptInApp {
+	for j := i - 1; j >= 0; j-- {
+		if txs[j].OriginalTransactionID == txs[i].OriginalTransactionID {
+			return txs[j]
+		}
+	}
+	return nil
+}
+
+// isUpgradedIn checks the transaction is cancelled by the upgrade to the other product or not
+func isUpgradedIn(txs ReceiptInApps, tx *ReceiptInApp) bool {
+	for _, v := range txs {
+		if v.OriginalTransactionID == tx.OriginalTransactionID &&
+			v.ProductID != tx.ProductID &&
+			v.PurchaseDate.Equal(tx.CancellationDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// purchaseEvent returns the event of the new transaction
+func purchaseEvent(tx, prevTx *ReceiptInApp, infos ReceiptPendingRenewalInfos) ReceiptEvent {
+	switch {
+	case tx.ExpiresDate.IsZero():
+		return transactionEvent(NotificationTypeV2OneTimeCharge, "", tx)
+	case prevTx == nil && tx.TransactionID == tx.OriginalTransactionID:
+		return transactionEvent(NotificationTypeV2Subscribed, NotificationSubtypeV2InitialBuy, tx)
+	case prevTx == nil:
+		return transactionEvent(NotificationTypeV2DidRenew, "", tx)
+	case prevTx.ProductID != tx.ProductID && prevTx.CancellationDate.Equal(tx.PurchaseDate):
+		return transactionEvent(NotificationTypeV2DidChangeRenewalPref, NotificationSubtypeV2Upgrade, tx)
+	}
+
+	if info := infos.byProductID(prevTx.ProductID); info != nil && info.RetryFlag {
+		return transactionEvent(NotificationTypeV2DidRenew, NotificationSubtypeV2BillingRecovery, tx)
+	}
+	if tx.PurchaseDate.After(prevTx.ExpiresDate) {
+		return transactionEvent(NotificationTypeV2Subscribed, NotificationSubtypeV2Resubscribe, tx)
+	}
+	return transactionEvent(NotificationTypeV2DidRenew, "", tx)
+}
+
+func transactionEvent(typ NotificationTypeV2, subtype NotificationSubtypeV2, tx *ReceiptInApp) ReceiptEvent {
+	return ReceiptEvent{
+		Type:                  typ,
+		Subtype:               subtype,
+		OriginalTransactionID: tx.OriginalTransactionID,
+		ProductID:             tx.ProductID,
+		InApp:                 tx,
+	}
+}
+
+// renewalInfoEvents returns the events of the changes of pending_renewal_info
+func (r *Receipt) renewalInfoEvents(old, info *ReceiptPendingRenewalInfo) []ReceiptEvent {
+	event := ReceiptEvent{
+		ProductID:   info.ProductID,
+		RenewalInfo: info,
+	}
+	if tx := r.GetLastExpiresByProductID(info.ProductID); tx != nil {
+		event.OriginalTransactionID = tx.OriginalTransactionID
+	}
+
+	var events []ReceiptEvent
+	add := func(typ NotificationTypeV2, subtype NotificationSubtypeV2) {
+		e := event
+		e.Type = typ
+		e.Subtype = subtype
+		events = append(events, e)
+	}
+
+	switch {
+	case !old.AutoRenewStatus && info.AutoRenewStatus:
+		add(NotificationTypeV2DidChangeRenewalStatus, NotificationSubtypeV2AutoRenewEnabled)
+	case old.AutoRenewStatus && !info.AutoRenewStatus:
+		add(NotificationTypeV2DidChangeRenewalStatus, NotificationSubtypeV2AutoRenewDisabled)
+	}
+	switch {
+	case old.AutoRenewProductID == info.AutoRenewProductID:
+	case info.IsDifferentAutoRenewProductID():
+		add(NotificationTypeV2DidChangeRenewalPref, NotificationSubtypeV2Downgrade)
+	case old.IsDifferentAutoRenewProductID():
+		// the scheduled change is cancelled
+		add(NotificationTypeV2DidChangeRenewalPref, "")
+	}
+	if !old.RetryFlag && info.RetryFlag {
+		add(NotificationTypeV2DidFailToRenew, "")
+	}
+	return events
+}
@@ -0,0 +1,213 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testDiffStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testDiffInApp(id, originalID int64, productID string, day, days int) *ReceiptInApp {
+	v := &ReceiptInApp{
+		TransactionID:         id,
+		OriginalTransactionID: originalID,
+		ProductID:             productID,
+		PurchaseDate:          testDiffStart.AddDate(0, 0, day),
+	}
+	if days > 0 {
+		v.ExpiresDate = v.PurchaseDate.AddDate(0, 0, days)
+	}
+	return v
+}
+
+func testDiffEventTypes(events []ReceiptEvent) []string {
+	list := make([]string, len(events))
+	for i, e := range events {
+		list[i] = string(e.Type) + "/" + string(e.Subtype)
+	}
+	return list
+}
+
+func TestDiffTransactions(t *testing.T) {
+	assert := assert.New(t)
+
+	next := &Receipt{
+		InApps: ReceiptInApps{
+			testDiffInApp(1, 1, "coin", 0, 0),
+			testDiffInApp(2, 2, "monthly", 1, 30),
+		},
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(2, 2, "monthly", 1, 30),
+			testDiffInApp(3, 2, "monthly", 31, 30),
+		},
+	}
+	events := Diff(nil, next)
+	assert.Equal([]string{
+		"ONE_TIME_CHARGE/",
+		"SUBSCRIBED/INITIAL_BUY",
+		"DID_RENEW/",
+	}, testDiffEventTypes(events))
+	assert.Equal(int64(2), events[2].OriginalTransactionID)
+	assert.Equal(int64(3), events[2].InApp.TransactionID)
+	assert.Equal("monthly", events[2].ProductID)
+
+	// no change
+	assert.Empty(Diff(next, next))
+
+	// refund, resubscribe and another subscription
+	refunded := testDiffInApp(1, 1, "coin", 0, 0)
+	refunded.CancellationDate = testDiffStart.AddDate(0, 0, 70)
+	next2 := &Receipt{
+		InApps: ReceiptInApps{refunded},
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(2, 2, "monthly", 1, 30),
+			testDiffInApp(3, 2, "monthly", 31, 30),
+			testDiffInApp(4, 2, "monthly", 80, 30),
+			testDiffInApp(5, 5, "weekly", 81, 7),
+		},
+	}
+	events = Diff(next, next2)
+	assert.Equal([]string{
+		"REFUND/",
+		"SUBSCRIBED/RESUBSCRIBE",
+		"SUBSCRIBED/INITIAL_BUY",
+	}, testDiffEventTypes(events))
+	assert.Equal(int64(1), events[0].InApp.TransactionID)
+
+	assert.Equal([]string{"REFUND_REVERSED/"}, testDiffEventTypes(Diff(next2, &Receipt{
+		InApps:            ReceiptInApps{testDiffInApp(1, 1, "coin", 0, 0)},
+		LatestReceiptInfo: next2.LatestReceiptInfo,
+	})))
+}
+
+func TestDiffUpgradeAndBillingRecovery(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := &Receipt{
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(1, 1, "monthly", 0, 30),
+		},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{
+			{ProductID: "monthly", AutoRenewProductID: "monthly", AutoRenewStatus: true, RetryFlag: true},
+		},
+	}
+
+	// renewed after the billing retry
+	next := &Receipt{
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(1, 1, "monthly", 0, 30),
+			testDiffInApp(2, 1, "monthly", 35, 30),
+		},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{
+			{ProductID: "monthly", AutoRenewProductID: "monthly", AutoRenewStatus: true},
+		},
+	}
+	assert.Equal([]string{"DID_RENEW/BILLING_RECOVERY"}, testDiffEventTypes(Diff(prev, next)))
+
+	// upgraded in the middle of the period
+	upgraded := testDiffInApp(2, 1, "monthly", 35, 30)
+	upgraded.CancellationDate = testDiffStart.AddDate(0, 0, 40)
+	next2 := &Receipt{
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(1, 1, "monthly", 0, 30),
+			upgraded,
+			testDiffInApp(3, 1, "monthly_pro", 40, 30),
+		},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{
+			{ProductID: "monthly_pro", AutoRenewProductID: "monthly_pro", AutoRenewStatus: true},
+		},
+	}
+	events := Diff(next, next2)
+	assert.Equal([]string{"DID_CHANGE_RENEWAL_PREF/UPGRADE"}, testDiffEventTypes(events))
+	assert.Equal("monthly_pro", events[0].ProductID)
+}
+
+func TestDiffUpgradeWithRenewalInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := &Receipt{
+		LatestReceiptInfo: ReceiptInApps{
+			testDiffInApp(1, 1, "monthly", 0, 30),
+		},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{
+			{ProductID: "monthly", AutoRenewProductID: "monthly", AutoRenewStatus: true},
+		},
+	}
+
+	// upgraded, and auto-renew is turned off and billing retry is started in the same poll
+	upgraded := testDiffInApp(1, 1, "monthly", 0, 30)
+	upgraded.CancellationDate = testDiffStart.AddDate(0, 0, 10)
+	next := &Receipt{
+		LatestReceiptInfo: ReceiptInApps{
+			upgraded,
+			testDiffInApp(2, 1, "monthly_pro", 10, 30),
+		},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{
+			{ProductID: "monthly_pro", AutoRenewProductID: "monthly_pro", RetryFlag: true},
+		},
+	}
+	events := Diff(prev, next)
+	assert.Equal([]string{
+		"DID_CHANGE_RENEWAL_PREF/UPGRADE",
+		"DID_CHANGE_RENEWAL_STATUS/AUTO_RENEW_DISABLED",
+		"DID_FAIL_TO_RENEW/",
+	}, testDiffEventTypes(events))
+	for _, e := range events[1:] {
+		assert.Equal(int64(1), e.OriginalTransactionID)
+		assert.Equal("monthly_pro", e.ProductID)
+	}
+}
+
+func TestDiffNil(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &Receipt{
+		LatestReceiptInfo:  ReceiptInApps{testDiffInApp(1, 1, "monthly", 0, 30)},
+		PendingRenewalInfo: ReceiptPendingRenewalInfos{{ProductID: "monthly", AutoRenewStatus: true}},
+	}
+	assert.Empty(Diff(r, nil))
+	assert.Empty(Diff(nil, nil))
+	assert.Equal([]string{"SUBSCRIBED/INITIAL_BUY"}, testDiffEventTypes(Diff(nil, r)))
+}
+
+func TestDiffPendingRenewalInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	txs := ReceiptInApps{testDiffInApp(1, 1, "monthly", 0, 30)}
+	receipt := func(info ReceiptPendingRenewalInfo) *Receipt {
+		return &Receipt{
+			LatestReceiptInfo:  txs,
+			PendingRenewalInfo: ReceiptPendingRenewalInfos{&info},
+		}
+	}
+	on := ReceiptPendingRenewalInfo{ProductID: "monthly", AutoRenewProductID: "monthly", AutoRenewStatus: true}
+	off := ReceiptPendingRenewalInfo{ProductID: "monthly", AutoRenewProductID: "monthly", ExpirationIntent: 1}
+	downgrade := ReceiptPendingRenewalInfo{ProductID: "monthly", AutoRenewProductID: "monthly_lite", AutoRenewStatus: true}
+	retry := ReceiptPendingRenewalInfo{ProductID: "monthly", AutoRenewProductID: "monthly", AutoRenewStatus: true, RetryFlag: true}
+
+	tests := []struct {
+		prev     ReceiptPendingRenewalInfo
+		next     ReceiptPendingRenewalInfo
+		expected []string
+	}{
+		{on, off, []string{"DID_CHANGE_RENEWAL_STATUS/AUTO_RENEW_DISABLED"}},
+		{off, on, []string{"DID_CHANGE_RENEWAL_STATUS/AUTO_RENEW_ENABLED"}},
+		{on, downgrade, []string{"DID_CHANGE_RENEWAL_PREF/DOWNGRADE"}},
+		{downgrade, on, []string{"DID_CHANGE_RENEWAL_PREF/"}},
+		{on, retry, []string{"DID_FAIL_TO_RENEW/"}},
+		{off, retry, []string{"DID_CHANGE_RENEWAL_STATUS/AUTO_RENEW_ENABLED", "DID_FAIL_TO_RENEW/"}},
+		{on, on, []string{}},
+	}
+	for _, tt := range tests {
+		events := Diff(receipt(tt.prev), receipt(tt.next))
+		assert.Equal(tt.expected, testDiffEventTypes(events))
+		for _, e := range events {
+			assert.Equal(int64(1), e.OriginalTransactionID)
+			assert.Equal("monthly", e.ProductID)
+			assert.NotNil(e.RenewalInfo)
+			assert.Nil(e.InApp)
+		}
+	}
+}
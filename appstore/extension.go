@@ -0,0 +1,284 @@
+package appstore
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxExtendByDays is the maximum number of days to extend the renewal date at once
+	MaxExtendByDays = 90
+	// MaxExtensionsPerYear is the maximum number of extensions for a subscription in 365 days
+	MaxExtensionsPerYear = 2
+
+	extensionPeriod = 365 * 24 * time.Hour
+)
+
+// ErrExtensionLimitExceeded is returned when the subscription is already extended twice in a year
+var ErrExtensionLimitExceeded = errors.New("The subscription can be extended up to two times in a 365-day period.")
+
+// ExtendReasonCode is the reason code for the subscription date extension
+type ExtendReasonCode int32
+
+const (
+	ExtendReasonCodeUndeclared ExtendReasonCode = iota
+	ExtendReasonCodeCustomerSatisfaction
+	ExtendReasonCodeOther
+	ExtendReasonCodeServiceIssueOrOutage
+)
+
+// ExtendRenewalDateRequest is the request body to extend the renewal date of a subscription
+// see: https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldaterequest
+type ExtendRenewalDateRequest struct {
+	ExtendByDays      int32            `json:"extendByDays"`
+	ExtendReasonCode  ExtendReasonCode `json:"extendReasonCode"`
+	RequestIdentifier string           `json:"requestIdentifier"`
+}
+
+// Validate checks the request satisfies the limits of the App Store
+func (r ExtendRenewalDateRequest) Validate() error {
+	return validateExtension(r.ExtendByDays, r.ExtendReasonCode, r.RequestIdentifier)
+}
+
+// ExtendRenewalDateResponse is the response of the renewal date extension
+type ExtendRenewalDateResponse struct {
+	OriginalTransactionID string `json:"originalTransactionId"`
+	WebOrderLineItemID    string `json:"webOrderLineItemId"`
+	Success               bool   `json:"success"`
+	EffectiveDate         int64  `json:"effectiveDate"`
+}
+
+// GetEffectiveDate returns the new renewal date of the subscription
+func (r *ExtendRenewalDateResponse) GetEffectiveDate() time.Time {
+	return msToTime(r.EffectiveDate)
+}
+
+// MassExtendRenewalDateRequest is the request body to extend the renewal date of all of active subscribers
+// see: https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldaterequest
+type MassExtendRenewalDateRequest struct {
+	ExtendByDays           int32            `json:"extendByDays"`
+	ExtendReasonCode       ExtendReasonCode `json:"extendReasonCode"`
+	RequestIdentifier      string           `json:"requestIdentifier"`
+	StorefrontCountryCodes []string         `json:"storefrontCountryCodes,omitempty"`
+	ProductID              string           `json:"productId"`
+}
+
+// Validate checks the request satisfies the limits of the App Store
+func (r MassExtendRenewalDateRequest) Validate() error {
+	if r.ProductID == "" {
+		return errors.New("productId is required.")
+	}
+	return validateExtension(r.ExtendByDays, r.ExtendReasonCode, r.RequestIdentifier)
+}
+
+// MassExtendRenewalDateStatus is the status of the mass extension request
+// see: https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldatestatusresponse
+type MassExtendRenewalDateStatus struct {
+	RequestIdentifier string `json:"requestIdentifier"`
+	Complete          bool   `json:"complete"`
+	CompleteDate      int64  `json:"completeDate"`
+	SucceededCount    int64  `json:"succeededCount"`
+	FailedCount       int64  `json:"failedCount"`
+}
+
+// GetCompleteDate returns the date when the mass extension request is completed
+func (s *MassExtendRenewalDateStatus) GetCompleteDate() time.Time {
+	return msToTime(s.CompleteDate)
+}
+
+func validateExtension(days int32, reason ExtendReasonCode, requestIdentifier string) error {
+	switch {
+	case days < 1 || days > MaxExtendByDays:
+		return errors.New("extendByDays must be between 1 and 90.")
+	case reason < ExtendReasonCodeUndeclared || reason > ExtendReasonCodeServiceIssueOrOutage:
+		return errors.New("extendReasonCode is invalid.")
+	case requestIdentifier == "" || len(requestIdentifier) > 128:
+		return errors.New("requestIdentifier must be 1 to 128 characters.")
+	}
+	return nil
+}
+
+// ExtensionHistory stores the dates of renewal date extensions for each subscription
+// to check the limit of extensions before sending requests.
+// ReserveExtension must check the limit and record the extension atomically,
+// so that concurrent requests for the same subscription cannot exceed the limit.
+type ExtensionHistory interface {
+	Extensions(originalTransactionID string) ([]time.Time, error)
+	AddExtension(originalTransactionID string, at time.Time) error
+	// ReserveExtension records the extension at the time when the subscription is extended
+	// less than MaxExtensionsPerYear times in 365 days, otherwise returns ErrExtensionLimitExceeded.
+	ReserveExtension(originalTransactionID string, at time.Time) error
+	// ReleaseExtension removes the extension recorded by ReserveExtension
+	ReleaseExtension(originalTransactionID string, at time.Time) error
+}
+
+// MemoryExtensionHistory is ExtensionHistory on memory
+type MemoryExtensionHistory struct {
+	mu    sync.Mutex
+	dates map[string][]time.Time
+}
+
+// NewMemoryExtensionHistory creates MemoryExtensionHistory
+func NewMemoryExtensionHistory() *MemoryExtensionHistory {
+	return &MemoryExtensionHistory{
+		dates: make(map[string][]time.Time),
+	}
+}
+
+// Extensions returns the dates of extensions for the subscription
+func (h *MemoryExtensionHistory) Extensions(originalTransactionID string) ([]time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]time.Time(nil), h.dates[originalTransactionID]...), nil
+}
+
+// AddExtension records the extension for the subscription without checking the limit
+func (h *MemoryExtensionHistory) AddExtension(originalTransactionID string, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dates[originalTransactionID] = append(h.dates[originalTransactionID], at)
+	return nil
+}
+
+// ReserveExtension records the extension for the subscription when it doesn't exceed the limit
+func (h *MemoryExtensionHistory) ReserveExtension(originalTransactionID string, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := checkExtensionLimit(h.dates[originalTransactionID], at); err != nil {
+		return err
+	}
+	h.dates[originalTransactionID] = append(h.dates[originalTransactionID], at)
+	return nil
+}
+
+// ReleaseExtension removes the extension at the time for the subscription
+func (h *MemoryExtensionHistory) ReleaseExtension(originalTransactionID string, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	dates := h.dates[originalTransactionID]
+	for i, v := range dates {
+		if v.Equal(at) {
+			h.dates[originalTransactionID] = append(dates[:i:i], dates[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// checkExtensionLimit checks the subscription is extended less than two times in 365 days
+func checkExtensionLimit(dates []time.Time, now time.Time) error {
+	count := 0
+	for _, v := range dates {
+		if now.Sub(v) < extensionPeriod {
+			count++
+		}
+	}
+	if count >= MaxExtensionsPerYear {
+		return ErrExtensionLimitExceeded
+	}
+	return nil
+}
+
+// ExtendSubscriptionRenewalDate extends the renewal date of the subscription.
+// When RequestIdentifier is empty, UUID is used for it.
+// The extension is reserved in ExtensionHistory before the request, and it's released only when the App Store
+// rejects the request (4xx with the error code, or success is false). The reservation is kept for the other errors
+// such as network errors and timeouts, because the App Store may have extended the subscription.
+// see: https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (c *ServerAPIClient) ExtendSubscriptionRenewalDate(originalTransactionID string, req ExtendRenewalDateRequest) (*ExtendRenewalDateResponse, error) {
+	if req.RequestIdentifier == "" {
+		req.RequestIdentifier = newUUID()
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := c.extensions.ReserveExtension(originalTransactionID, now); err != nil {
+		return nil, err
+	}
+
+	var result ExtendRenewalDateResponse
+	err := c.do(put, "/inApps/v1/subscriptions/extend/"+url.PathEscape(originalTransactionID), req, &result)
+	switch {
+	case err != nil:
+		if isRejectedByServerAPI(err) {
+			c.extensions.ReleaseExtension(originalTransactionID, now)
+		}
+		return nil, err
+	case !result.Success:
+		if err := c.extensions.ReleaseExtension(originalTransactionID, now); err != nil {
+			return &result, err
+		}
+	}
+	return &result, nil
+}
+
+// isRejectedByServerAPI checks the App Store surely didn't apply the request
+func isRejectedByServerAPI(err error) bool {
+	apiErr, ok := err.(*ServerAPIError)
+	return ok && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.ErrorCode != 0
+}
+
+// MassExtendSubscriptionRenewalDate extends the renewal date of all of active subscribers of the product
+// and returns the request identifier to check the status.
+// When RequestIdentifier is empty, UUID is used for it.
+// The App Store doesn't return the extended subscriptions, so they are not recorded in ExtensionHistory.
+// Call RecordMassExtension for each RENEWAL_EXTENDED notification to count them in the limit.
+// see: https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
+func (c *ServerAPIClient) MassExtendSubscriptionRenewalDate(req MassExtendRenewalDateRequest) (string, error) {
+	if req.RequestIdentifier == "" {
+		req.RequestIdentifier = newUUID()
+	}
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		RequestIdentifier string `json:"requestIdentifier"`
+	}
+	err := c.do(post, "/inApps/v1/subscriptions/extend/mass", req, &result)
+	if err != nil {
+		return "", err
+	}
+	if result.RequestIdentifier == "" {
+		return req.RequestIdentifier, nil
+	}
+	return result.RequestIdentifier, nil
+}
+
+// RecordMassExtension records the extension of the subscription by the mass extension request
+// in ExtensionHistory without checking the limit.
+func (c *ServerAPIClient) RecordMassExtension(originalTransactionID string, at time.Time) error {
+	return c.extensions.AddExtension(originalTransactionID, at)
+}
+
+// GetMassExtendRenewalDateStatus returns the status of the mass extension request
+// see: https://developer.apple.com/documentation/appstoreserverapi/get_status_of_subscription_renewal_date_extensions
+func (c *ServerAPIClient) GetMassExtendRenewalDateStatus(productID, requestIdentifier string) (*MassExtendRenewalDateStatus, error) {
+	var result MassExtendRenewalDateStatus
+	err := c.do(get, "/inApps/v1/subscriptions/extend/mass/"+url.PathEscape(productID)+"/"+url.PathEscape(requestIdentifier), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WaitMassExtendRenewalDate polls the status of the mass extension request in every interval
+// until it is completed or timeout is passed.
+func (c *ServerAPIClient) WaitMassExtendRenewalDate(productID, requestIdentifier string, interval, timeout time.Duration) (*MassExtendRenewalDateStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := c.GetMassExtendRenewalDateStatus(productID, requestIdentifier)
+		switch {
+		case err != nil:
+			return nil, err
+		case status.Complete:
+			return status, nil
+		case time.Now().Add(interval).After(deadline):
+			return status, errors.New("The mass extension request is not completed before timeout.")
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,249 @@
+package appstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendRenewalDateRequestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		valid bool
+		req   ExtendRenewalDateRequest
+	}{
+		{true, ExtendRenewalDateRequest{ExtendByDays: 1, RequestIdentifier: "id"}},
+		{true, ExtendRenewalDateRequest{ExtendByDays: 90, ExtendReasonCode: ExtendReasonCodeServiceIssueOrOutage, RequestIdentifier: "id"}},
+		{false, ExtendRenewalDateRequest{ExtendByDays: 0, RequestIdentifier: "id"}},
+		{false, ExtendRenewalDateRequest{ExtendByDays: 91, RequestIdentifier: "id"}},
+		{false, ExtendRenewalDateRequest{ExtendByDays: 1, ExtendReasonCode: 4, RequestIdentifier: "id"}},
+		{false, ExtendRenewalDateRequest{ExtendByDays: 1}},
+		{false, ExtendRenewalDateRequest{ExtendByDays: 1, RequestIdentifier: strings.Repeat("a", 129)}},
+	}
+	for _, tt := range tests {
+		err := tt.req.Validate()
+		assert.Equal(tt.valid, err == nil, "%+v", tt.req)
+	}
+
+	mass := MassExtendRenewalDateRequest{ExtendByDays: 30, RequestIdentifier: "id"}
+	assert.Error(mass.Validate())
+	mass.ProductID = "com.example.product.sub"
+	assert.NoError(mass.Validate())
+}
+
+func TestMemoryExtensionHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewMemoryExtensionHistory()
+	dates, err := h.Extensions("1")
+	assert.NoError(err)
+	assert.Len(dates, 0)
+
+	now := time.Now()
+	assert.NoError(h.AddExtension("1", now))
+	dates, err = h.Extensions("1")
+	assert.NoError(err)
+	assert.Equal([]time.Time{now}, dates)
+}
+
+func TestRecordMassExtension(t *testing.T) {
+	assert := assert.New(t)
+
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true}`)
+	})
+	defer server.Close()
+
+	now := time.Now()
+	assert.NoError(client.RecordMassExtension("2000000000", now.Add(-time.Hour)))
+	assert.NoError(client.RecordMassExtension("2000000000", now.Add(-time.Minute)))
+	_, err := client.ExtendSubscriptionRenewalDate("2000000000", ExtendRenewalDateRequest{ExtendByDays: 7})
+	assert.Equal(ErrExtensionLimitExceeded, err)
+}
+
+func TestExtendSubscriptionRenewalDate(t *testing.T) {
+	assert := assert.New(t)
+
+	count := 0
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		count++
+		assert.Equal("PUT", r.Method)
+		assert.Equal("/inApps/v1/subscriptions/extend/2000000000", r.URL.Path)
+
+		var req ExtendRenewalDateRequest
+		assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(int32(7), req.ExtendByDays)
+		assert.Len(req.RequestIdentifier, 36)
+		fmt.Fprint(w, `{"originalTransactionId":"2000000000","webOrderLineItemId":"1","success":true,"effectiveDate":1584600000000}`)
+	})
+	defer server.Close()
+
+	req := ExtendRenewalDateRequest{
+		ExtendByDays:     7,
+		ExtendReasonCode: ExtendReasonCodeServiceIssueOrOutage,
+	}
+	resp, err := client.ExtendSubscriptionRenewalDate("2000000000", req)
+	assert.NoError(err)
+	assert.True(resp.Success)
+	assert.Equal(time.Unix(1584600000, 0), resp.GetEffectiveDate())
+
+	_, err = client.ExtendSubscriptionRenewalDate("2000000000", req)
+	assert.NoError(err)
+
+	// third extension in a year is rejected before sending
+	_, err = client.ExtendSubscriptionRenewalDate("2000000000", req)
+	assert.Equal(ErrExtensionLimitExceeded, err)
+	assert.Equal(2, count)
+
+	// over 90 days is rejected before sending
+	_, err = client.ExtendSubscriptionRenewalDate("2000000001", ExtendRenewalDateRequest{ExtendByDays: 91})
+	assert.Error(err)
+	assert.Equal(2, count)
+}
+
+func TestExtensionLimitIsPerYear(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewMemoryExtensionHistory()
+	now := time.Now()
+	h.AddExtension("1", now.Add(-400*24*time.Hour))
+	h.AddExtension("1", now.Add(-100*24*time.Hour))
+	assert.NoError(h.ReserveExtension("1", now))
+	assert.Equal(ErrExtensionLimitExceeded, h.ReserveExtension("1", now.Add(time.Hour)))
+
+	assert.NoError(h.ReleaseExtension("1", now))
+	assert.NoError(h.ReserveExtension("1", now.Add(time.Hour)))
+	dates, _ := h.Extensions("1")
+	assert.Len(dates, 3)
+}
+
+func TestExtendSubscriptionRenewalDateConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	count := 0
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, `{"originalTransactionId":"2000000000","success":true}`)
+	})
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.ExtendSubscriptionRenewalDate("2000000000", ExtendRenewalDateRequest{ExtendByDays: 7})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	rejected := 0
+	for err := range errs {
+		if err == ErrExtensionLimitExceeded {
+			rejected++
+		}
+	}
+	assert.Equal(3, rejected)
+	assert.Equal(2, count)
+}
+
+func TestExtendSubscriptionRenewalDateReleasesOnRejection(t *testing.T) {
+	assert := assert.New(t)
+
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/inApps/v1/subscriptions/extend/2000000000":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errorCode":4000009,"errorMessage":"Invalid extend by days."}`)
+		case "/inApps/v1/subscriptions/extend/2000000002":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/inApps/v1/subscriptions/extend/2000000003":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			fmt.Fprint(w, `{"success":false}`)
+		}
+	})
+	defer server.Close()
+
+	req := ExtendRenewalDateRequest{ExtendByDays: 7}
+	_, err := client.ExtendSubscriptionRenewalDate("2000000000", req)
+	assert.Error(err)
+	resp, err := client.ExtendSubscriptionRenewalDate("2000000001", req)
+	assert.NoError(err)
+	assert.False(resp.Success)
+	for _, id := range []string{"2000000000", "2000000001"} {
+		dates, _ := client.extensions.Extensions(id)
+		assert.Empty(dates)
+	}
+
+	// the App Store may have extended the subscription
+	_, err = client.ExtendSubscriptionRenewalDate("2000000002", req)
+	assert.Error(err)
+	_, err = client.ExtendSubscriptionRenewalDate("2000000003", req)
+	assert.Error(err)
+	for _, id := range []string{"2000000002", "2000000003"} {
+		dates, _ := client.extensions.Extensions(id)
+		assert.Len(dates, 1)
+	}
+}
+
+func TestMassExtendSubscriptionRenewalDate(t *testing.T) {
+	assert := assert.New(t)
+
+	polled := 0
+	server, client, _ := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/inApps/v1/subscriptions/extend/mass":
+			var req MassExtendRenewalDateRequest
+			assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal("com.example.product.sub", req.ProductID)
+			assert.Equal([]string{"USA"}, req.StorefrontCountryCodes)
+			fmt.Fprintf(w, `{"requestIdentifier":%q}`, req.RequestIdentifier)
+		case r.Method == "GET" && r.URL.Path == "/inApps/v1/subscriptions/extend/mass/com.example.product.sub/mass-id":
+			polled++
+			if polled < 3 {
+				fmt.Fprint(w, `{"requestIdentifier":"mass-id","complete":false}`)
+				return
+			}
+			fmt.Fprint(w, `{"requestIdentifier":"mass-id","complete":true,"completeDate":1584600000000,"succeededCount":30,"failedCount":2}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	id, err := client.MassExtendSubscriptionRenewalDate(MassExtendRenewalDateRequest{
+		ExtendByDays:           3,
+		ExtendReasonCode:       ExtendReasonCodeServiceIssueOrOutage,
+		RequestIdentifier:      "mass-id",
+		StorefrontCountryCodes: []string{"USA"},
+		ProductID:              "com.example.product.sub",
+	})
+	assert.NoError(err)
+	assert.Equal("mass-id", id)
+
+	_, err = client.MassExtendSubscriptionRenewalDate(MassExtendRenewalDateRequest{ExtendByDays: 100, ProductID: "com.example.product.sub"})
+	assert.Error(err)
+
+	status, err := client.WaitMassExtendRenewalDate("com.example.product.sub", id, time.Millisecond, time.Second)
+	assert.NoError(err)
+	assert.True(status.Complete)
+	assert.Equal(int64(30), status.SucceededCount)
+	assert.Equal(int64(2), status.FailedCount)
+	assert.Equal(time.Unix(1584600000, 0), status.GetCompleteDate())
+	assert.Equal(3, polled)
+}
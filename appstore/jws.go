@@ -0,0 +1,237 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidJWS is returned when the signed payload is not a valid JWS
+	ErrInvalidJWS = errors.New("The signed payload is not a valid JWS.")
+	// ErrInvalidJWSSignature is returned when the signature of the signed payload cannot be verified
+	ErrInvalidJWSSignature = errors.New("The signature of the signed payload cannot be verified.")
+	// ErrInvalidJWSCertificate is returned when the certificate chain of the signed payload is not issued for the App Store
+	ErrInvalidJWSCertificate = errors.New("The certificate chain of the signed payload is not issued for the App Store.")
+	// ErrNoRootCertificates is returned when the root certificates to verify the signed payload are not given
+	ErrNoRootCertificates = errors.New("The root certificates to verify the signed payload are not given.")
+
+	// oidAppleWWDRIntermediate is the extension of Apple Worldwide Developer Relations intermediate certificates
+	oidAppleWWDRIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+	// oidAppleStoreKitLeaf is the extension of the certificates signing App Store payloads
+	oidAppleStoreKitLeaf = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+)
+
+// LoadRootCertificates creates the pool of root certificates in DER or PEM format.
+// The payloads from the App Store are verified with Apple Root CA - G3,
+// which can be downloaded from https://www.apple.com/certificateauthority/AppleRootCA-G3.cer
+// The local receipts are verified with Apple Inc. Root Certificate,
+// which can be downloaded from https://www.apple.com/appleca/AppleIncRootCertificate.cer
+func LoadRootCertificates(certs ...[]byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, data := range certs {
+		if block, _ := pem.Decode(data); block != nil {
+			data = block.Bytes
+		}
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// jwsHeader is the header of signed payloads from the App Store
+type jwsHeader struct {
+	Alg string   `json:"alg"`
+	X5c []string `json:"x5c"`
+}
+
+// parseSignedPayload verifies JWS signed by the App Store and decodes its payload into v.
+// The certificate chain of `x5c` is verified with roots, including the extensions of Apple intermediate and leaf,
+// and the signature is checked with the leaf certificate.
+func parseSignedPayload(signed string, roots *x509.CertPool, v interface{}) error {
+	if roots == nil {
+		return ErrNoRootCertificates
+	}
+
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return ErrInvalidJWS
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidJWS
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return ErrInvalidJWS
+	}
+	if header.Alg != "ES256" || len(header.X5c) == 0 {
+		return ErrInvalidJWS
+	}
+
+	certs := make([]*x509.Certificate, len(header.X5c))
+	for i, x := range header.X5c {
+		der, err := base64.StdEncoding.DecodeString(x)
+		if err != nil {
+			return ErrInvalidJWS
+		}
+		certs[i], err = x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := verifyAppleChain(certs, roots, time.Time{}); err != nil {
+		if err == errNotAppleCertificate {
+			return ErrInvalidJWSCertificate
+		}
+		return err
+	}
+
+	pub, ok := certs[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return ErrInvalidJWSSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verifyES256(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return ErrInvalidJWSSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidJWS
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// errNotAppleCertificate is returned when the certificate chain doesn't have the extensions of Apple
+var errNotAppleCertificate = errors.New("the certificate chain is not issued by Apple")
+
+// verifyAppleChain verifies the chain of the leaf certificate (the first one) at the time (now when it's zero) with roots
+// and checks the leaf and intermediate certificates have the extensions of Apple
+func verifyAppleChain(certs []*x509.Certificate, roots *x509.CertPool, at time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		if len(chain) >= 3 && hasExtension(chain[0], oidAppleStoreKitLeaf) && hasExtension(chain[1], oidAppleWWDRIntermediate) {
+			return nil
+		}
+	}
+	return errNotAppleCertificate
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// signES256 signs the data with ES256 and returns the signature in JWS format (R || S)
+func signES256(key *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	hash := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	return sig, nil
+}
+
+// verifyES256 verifies the signature in JWS format (R || S) of the data
+func verifyES256(pub *ecdsa.PublicKey, data, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	hash := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	return ecdsa.Verify(pub, hash[:], r, s)
+}
+
+// JWSTransaction is the decoded payload of signed transaction information
+// see: https://developer.apple.com/documentation/appstoreserverapi/jwstransactiondecodedpayload
+type JWSTransaction struct {
+	TransactionID               string `json:"transactionId"`
+	OriginalTransactionID       string `json:"originalTransactionId"`
+	WebOrderLineItemID          string `json:"webOrderLineItemId"`
+	BundleID                    string `json:"bundleId"`
+	ProductID                   string `json:"productId"`
+	SubscriptionGroupIdentifier string `json:"subscriptionGroupIdentifier"`
+	PurchaseDate                int64  `json:"purchaseDate"`
+	OriginalPurchaseDate        int64  `json:"originalPurchaseDate"`
+	ExpiresDate                 int64  `json:"expiresDate"`
+	Quantity                    int64  `json:"quantity"`
+	Type                        string `json:"type"`
+	AppAccountToken             string `json:"appAccountToken"`
+	InAppOwnershipType          string `json:"inAppOwnershipType"`
+	SignedDate                  int64  `json:"signedDate"`
+	RevocationReason            *int64 `json:"revocationReason"`
+	RevocationDate              int64  `json:"revocationDate"`
+	IsUpgraded                  bool   `json:"isUpgraded"`
+	OfferType                   int64  `json:"offerType"`
+	OfferIdentifier             string `json:"offerIdentifier"`
+	OfferDiscountType           string `json:"offerDiscountType"`
+	Environment                 string `json:"environment"`
+	Storefront                  string `json:"storefront"`
+	StorefrontID                string `json:"storefrontId"`
+	TransactionReason           string `json:"transactionReason"`
+	Currency                    string `json:"currency"`
+	Price                       int64  `json:"price"`
+}
+
+// ParseSignedTransaction verifies and decodes signed transaction information.
+// roots is required to verify the certificate chain in the JWS header (see LoadRootCertificates).
+func ParseSignedTransaction(signed string, roots *x509.CertPool) (*JWSTransaction, error) {
+	var tx JWSTransaction
+	if err := parseSignedPayload(signed, roots, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// ToReceiptInApp converts signed transaction information into ReceiptInApp
+func (tx *JWSTransaction) ToReceiptInApp() *ReceiptInApp {
+	return &ReceiptInApp{
+		Quantity:              tx.Quantity,
+		ProductID:             tx.ProductID,
+		TransactionID:         ToInt64(tx.TransactionID),
+		OriginalTransactionID: ToInt64(tx.OriginalTransactionID),
+		IsTrialPeriod:         tx.OfferDiscountType == "FREE_TRIAL",
+		WebOrderLineItemID:    ToInt64(tx.WebOrderLineItemID),
+		InAppOwnershipType:    tx.InAppOwnershipType,
+		PurchaseDate:          msToTime(tx.PurchaseDate),
+		OriginalPurchaseDate:  msToTime(tx.OriginalPurchaseDate),
+		ExpiresDate:           msToTime(tx.ExpiresDate),
+		CancellationDate:      msToTime(tx.RevocationDate),
+	}
+}
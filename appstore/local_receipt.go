@@ -0,0 +1,237 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrReceiptHashMismatch is returned when the receipt is not issued for the device
+	ErrReceiptHashMismatch = errors.New("The receipt hash does not match the device.")
+	// ErrReceiptBundleIDMismatch is returned when the receipt is not issued for the app
+	ErrReceiptBundleIDMismatch = errors.New("The bundle id in the receipt does not match.")
+	// ErrReceiptVersionMismatch is returned when the receipt is not issued for the app version
+	ErrReceiptVersionMismatch = errors.New("The application version in the receipt does not match.")
+)
+
+// attribute types of the local receipt
+// see: https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ReceiptFields.html
+const (
+	receiptAttrBundleID                   = 2
+	receiptAttrApplicationVersion         = 3
+	receiptAttrOpaqueValue                = 4
+	receiptAttrHash                       = 5
+	receiptAttrCreationDate               = 12
+	receiptAttrInApp                      = 17
+	receiptAttrOriginalApplicationVersion = 19
+	receiptAttrExpirationDate             = 21
+
+	inAppAttrQuantity              = 1701
+	inAppAttrProductID             = 1702
+	inAppAttrTransactionID         = 1703
+	inAppAttrPurchaseDate          = 1704
+	inAppAttrOriginalTransactionID = 1705
+	inAppAttrOriginalPurchaseDate  = 1706
+	inAppAttrExpiresDate           = 1708
+	inAppAttrWebOrderLineItemID    = 1711
+	inAppAttrCancellationDate      = 1712
+	inAppAttrIsTrialPeriod         = 1713
+)
+
+type receiptAttribute struct {
+	Type    int
+	Version int
+	Value   []byte
+}
+
+// LocalReceipt is the receipt file in the app bundle, which is validated on your server
+// without verifyReceipt API.
+// see: https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ValidateLocally.html
+type LocalReceipt struct {
+	BundleID                   string
+	ApplicationVersion         string
+	OriginalApplicationVersion string
+	CreationDate               time.Time
+	ExpirationDate             time.Time
+	InApps                     ReceiptInApps
+
+	bundleIDRaw []byte
+	opaqueValue []byte
+	hash        []byte
+}
+
+// ParseLocalReceipt parses the receipt file (PKCS#7 container, not base64 encoded).
+// Note that the signature of PKCS#7 is not verified, use VerifyLocalReceipt to trust the content.
+func ParseLocalReceipt(data []byte) (*LocalReceipt, error) {
+	content, err := parsePKCS7Content(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocalReceiptContent(content)
+}
+
+// VerifyLocalReceipt parses the receipt file and verifies its signature
+// with the certificate chain issued by Apple under roots (Apple Inc. Root, see LoadRootCertificates).
+// The chain is verified at the creation date of the receipt.
+func VerifyLocalReceipt(data []byte, roots *x509.CertPool) (*LocalReceipt, error) {
+	if roots == nil {
+		return nil, ErrNoRootCertificates
+	}
+	p, err := parsePKCS7(data)
+	if err != nil {
+		return nil, err
+	}
+	r, err := parseLocalReceiptContent(p.content)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.verify(roots, r.CreationDate); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseLocalReceiptContent(content []byte) (*LocalReceipt, error) {
+	attrs, err := parseReceiptAttributes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LocalReceipt{}
+	for _, attr := range attrs {
+		switch attr.Type {
+		case receiptAttrBundleID:
+			r.bundleIDRaw = attr.Value
+			r.BundleID = asn1String(attr.Value)
+		case receiptAttrApplicationVersion:
+			r.ApplicationVersion = asn1String(attr.Value)
+		case receiptAttrOpaqueValue:
+			r.opaqueValue = attr.Value
+		case receiptAttrHash:
+			r.hash = attr.Value
+		case receiptAttrCreationDate:
+			r.CreationDate = asn1Time(attr.Value)
+		case receiptAttrOriginalApplicationVersion:
+			r.OriginalApplicationVersion = asn1String(attr.Value)
+		case receiptAttrExpirationDate:
+			r.ExpirationDate = asn1Time(attr.Value)
+		case receiptAttrInApp:
+			inApp, err := parseLocalReceiptInApp(attr.Value)
+			if err != nil {
+				return nil, err
+			}
+			r.InApps = append(r.InApps, inApp)
+		}
+	}
+	return r, nil
+}
+
+// ValidateLocalReceipt verifies the receipt file from macOS app with roots (see VerifyLocalReceipt)
+// and checks it is issued for the device, the app and the version.
+// guid is the device identifier (MAC address of the primary network interface).
+// Version check is skipped when version is empty.
+func ValidateLocalReceipt(data, guid []byte, bundleID, version string, roots *x509.CertPool) (*LocalReceipt, error) {
+	r, err := VerifyLocalReceipt(data, roots)
+	switch {
+	case err != nil:
+		return nil, err
+	case !r.VerifyHash(guid):
+		return nil, ErrReceiptHashMismatch
+	case r.BundleID != bundleID:
+		return nil, ErrReceiptBundleIDMismatch
+	case version != "" && r.ApplicationVersion != version:
+		return nil, ErrReceiptVersionMismatch
+	}
+	return r, nil
+}
+
+// VerifyHash checks SHA-1 hash of the device identifier, opaque value and bundle id
+// matches the hash in the receipt.
+func (r *LocalReceipt) VerifyHash(guid []byte) bool {
+	if len(r.hash) == 0 {
+		return false
+	}
+	h := sha1.New()
+	h.Write(guid)
+	h.Write(r.opaqueValue)
+	h.Write(r.bundleIDRaw)
+	return bytes.Equal(h.Sum(nil), r.hash)
+}
+
+// GetOriginalApplicationVersion returns the app version which the user purchased at first
+func (r *LocalReceipt) GetOriginalApplicationVersion() string {
+	return r.OriginalApplicationVersion
+}
+
+func parseReceiptAttributes(data []byte) ([]receiptAttribute, error) {
+	var attrs []receiptAttribute
+	if _, err := asn1.UnmarshalWithParams(data, &attrs, "set"); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+func parseLocalReceiptInApp(data []byte) (*ReceiptInApp, error) {
+	attrs, err := parseReceiptAttributes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	inApp := &ReceiptInApp{}
+	for _, attr := range attrs {
+		switch attr.Type {
+		case inAppAttrQuantity:
+			inApp.Quantity = asn1Int(attr.Value)
+		case inAppAttrProductID:
+			inApp.ProductID = asn1String(attr.Value)
+		case inAppAttrTransactionID:
+			inApp.TransactionID = ToInt64(asn1String(attr.Value))
+		case inAppAttrOriginalTransactionID:
+			inApp.OriginalTransactionID = ToInt64(asn1String(attr.Value))
+		case inAppAttrPurchaseDate:
+			inApp.PurchaseDate = asn1Time(attr.Value)
+		case inAppAttrOriginalPurchaseDate:
+			inApp.OriginalPurchaseDate = asn1Time(attr.Value)
+		case inAppAttrExpiresDate:
+			inApp.ExpiresDate = asn1Time(attr.Value)
+		case inAppAttrCancellationDate:
+			inApp.CancellationDate = asn1Time(attr.Value)
+		case inAppAttrWebOrderLineItemID:
+			inApp.WebOrderLineItemID = asn1Int(attr.Value)
+		case inAppAttrIsTrialPeriod:
+			inApp.IsTrialPeriod = asn1Int(attr.Value) == 1
+		}
+	}
+	return inApp, nil
+}
+
+// asn1String decodes UTF8String or IA5String in the attribute value
+func asn1String(b []byte) string {
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(b, &raw); err != nil {
+		return ""
+	}
+	return string(raw.Bytes)
+}
+
+// asn1Int decodes INTEGER in the attribute value
+func asn1Int(b []byte) int64 {
+	var v int64
+	if _, err := asn1.Unmarshal(b, &v); err != nil {
+		return 0
+	}
+	return v
+}
+
+// asn1Time decodes the date in RFC 3339 format in the attribute value
+func asn1Time(b []byte) time.Time {
+	t, err := time.Parse(time.RFC3339, asn1String(b))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
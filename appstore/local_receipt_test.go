@@ -0,0 +1,362 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testGUID = []byte{0x00, 0x1c, 0x42, 0x00, 0x00, 0x08}
+
+// testTLV encodes ASN.1 value in DER, or in BER with indefinite length when indefinite is true
+func testTLV(tag byte, indefinite bool, body ...[]byte) []byte {
+	var b []byte
+	for _, v := range body {
+		b = append(b, v...)
+	}
+	if indefinite {
+		return append(append([]byte{tag, 0x80}, b...), 0x00, 0x00)
+	}
+	return append(append([]byte{tag}, encodeLength(len(b))...), b...)
+}
+
+func testMarshal(t *testing.T, v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testReceiptAttr(typ int, value []byte) receiptAttribute {
+	return receiptAttribute{Type: typ, Version: 1, Value: value}
+}
+
+func testUTF8(t *testing.T, s string) []byte {
+	b, err := asn1.MarshalWithParams(s, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testIA5(t *testing.T, s string) []byte {
+	b, err := asn1.MarshalWithParams(s, "ia5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testLocalReceiptPayload builds the content of the receipt file
+func testLocalReceiptPayload(t *testing.T, guid []byte, version string) []byte {
+	bundleID := testUTF8(t, "com.example.macapp")
+	opaque := []byte{0x01, 0x02, 0x03, 0x04}
+	hash := sha1.Sum(append(append(append([]byte{}, guid...), opaque...), bundleID...))
+
+	inApp, err := asn1.MarshalWithParams([]receiptAttribute{
+		testReceiptAttr(inAppAttrQuantity, testMarshal(t, 1)),
+		testReceiptAttr(inAppAttrProductID, testUTF8(t, "com.example.product.sub")),
+		testReceiptAttr(inAppAttrTransactionID, testUTF8(t, "90000000000002")),
+		testReceiptAttr(inAppAttrOriginalTransactionID, testUTF8(t, "90000000000001")),
+		testReceiptAttr(inAppAttrPurchaseDate, testIA5(t, "2020-02-18T04:26:40Z")),
+		testReceiptAttr(inAppAttrExpiresDate, testIA5(t, "2020-03-18T04:26:40Z")),
+		testReceiptAttr(inAppAttrCancellationDate, testIA5(t, "")),
+		testReceiptAttr(inAppAttrWebOrderLineItemID, testMarshal(t, 70000000000002)),
+		testReceiptAttr(inAppAttrIsTrialPeriod, testMarshal(t, 1)),
+	}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := asn1.MarshalWithParams([]receiptAttribute{
+		testReceiptAttr(receiptAttrBundleID, bundleID),
+		testReceiptAttr(receiptAttrApplicationVersion, testUTF8(t, version)),
+		testReceiptAttr(receiptAttrOpaqueValue, opaque),
+		testReceiptAttr(receiptAttrHash, hash[:]),
+		testReceiptAttr(receiptAttrCreationDate, testIA5(t, "2020-02-18T04:26:41Z")),
+		testReceiptAttr(receiptAttrOriginalApplicationVersion, testUTF8(t, "1.0")),
+		testReceiptAttr(receiptAttrInApp, inApp),
+	}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// testPKCS7 wraps the content in PKCS#7 signed data with the encoded certificates and signer infos
+func testPKCS7(t *testing.T, payload []byte, indefinite bool, certificates, signerInfos []byte) []byte {
+	content := testTLV(0x04, false, payload)
+	if indefinite {
+		// constructed OCTET STRING in two chunks
+		content = testTLV(0x24, true, testTLV(0x04, false, payload[:10]), testTLV(0x04, false, payload[10:]))
+	}
+	body := [][]byte{
+		testMarshal(t, 1),
+		testTLV(0x31, false),
+		testTLV(0x30, indefinite, testMarshal(t, oidData), testTLV(0xa0, indefinite, content)),
+	}
+	if certificates != nil {
+		body = append(body, testTLV(0xa0, false, certificates))
+	}
+	body = append(body, testTLV(0x31, false, signerInfos))
+	signedData := testTLV(0x30, indefinite, body...)
+	return testTLV(0x30, indefinite, testMarshal(t, oidSignedData), testTLV(0xa0, indefinite, signedData))
+}
+
+// testLocalReceiptData builds the unsigned receipt file of PKCS#7 container
+func testLocalReceiptData(t *testing.T, guid []byte, indefinite bool) []byte {
+	return testPKCS7(t, testLocalReceiptPayload(t, guid, "1.2.0"), indefinite, nil, nil)
+}
+
+// testReceiptSigner signs the receipt file as the App Store does, with its own certificate chain
+type testReceiptSigner struct {
+	key   crypto.Signer
+	certs []*x509.Certificate
+	roots *x509.CertPool
+}
+
+func newTestReceiptSigner(t *testing.T, key crypto.Signer, appleExtensions bool) *testReceiptSigner {
+	certs, roots := newTestCertChain(t, key, appleExtensions)
+	return &testReceiptSigner{key: key, certs: certs, roots: roots}
+}
+
+// sign returns the signed receipt file of the payload.
+// When withAttributes is true, the signature is made over the authenticated attributes.
+func (s *testReceiptSigner) sign(t *testing.T, payload []byte, hash crypto.Hash, withAttributes bool) []byte {
+	digestOID := oidSHA1
+	if hash == crypto.SHA256 {
+		digestOID = oidSHA256
+	}
+	h := hash.New()
+	h.Write(payload)
+	contentDigest := h.Sum(nil)
+
+	var attrs []byte
+	signed := payload
+	if withAttributes {
+		attrs = append(
+			testTLV(0x30, false, testMarshal(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}), testTLV(0x31, false, testMarshal(t, oidData))),
+			testTLV(0x30, false, testMarshal(t, oidMessageDigest), testTLV(0x31, false, testMarshal(t, contentDigest)))...,
+		)
+		signed = testTLV(0x31, false, attrs)
+	}
+	h = hash.New()
+	h.Write(signed)
+	sig, err := s.key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := s.certs[0]
+	signerInfo := [][]byte{
+		testMarshal(t, 1),
+		testTLV(0x30, false, leaf.RawIssuer, testMarshal(t, leaf.SerialNumber)),
+		testMarshal(t, pkix.AlgorithmIdentifier{Algorithm: digestOID}),
+	}
+	if withAttributes {
+		signerInfo = append(signerInfo, testTLV(0xa0, false, attrs))
+	}
+	signerInfo = append(signerInfo,
+		testMarshal(t, pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}}),
+		testMarshal(t, sig),
+	)
+
+	var certificates []byte
+	for _, cert := range s.certs {
+		certificates = append(certificates, cert.Raw...)
+	}
+	return testPKCS7(t, payload, false, certificates, testTLV(0x30, false, signerInfo...))
+}
+
+func TestParseLocalReceipt(t *testing.T) {
+	for _, indefinite := range []bool{false, true} {
+		assert := assert.New(t)
+
+		r, err := ParseLocalReceipt(testLocalReceiptData(t, testGUID, indefinite))
+		assert.NoError(err)
+		assert.Equal("com.example.macapp", r.BundleID)
+		assert.Equal("1.2.0", r.ApplicationVersion)
+		assert.Equal("1.0", r.GetOriginalApplicationVersion())
+		assert.Equal(time.Date(2020, 2, 18, 4, 26, 41, 0, time.UTC), r.CreationDate)
+		assert.True(r.ExpirationDate.IsZero())
+
+		assert.Len(r.InApps, 1)
+		inApp := r.InApps[0]
+		assert.Equal(int64(1), inApp.Quantity)
+		assert.Equal("com.example.product.sub", inApp.ProductID)
+		assert.Equal(int64(90000000000002), inApp.TransactionID)
+		assert.Equal(int64(90000000000001), inApp.OriginalTransactionID)
+		assert.Equal(int64(70000000000002), inApp.WebOrderLineItemID)
+		assert.True(inApp.IsTrialPeriod)
+		assert.Equal(time.Date(2020, 3, 18, 4, 26, 40, 0, time.UTC), inApp.ExpiresDate)
+		assert.True(inApp.CancellationDate.IsZero())
+		assert.True(r.InApps.IsAutoRenewable())
+	}
+}
+
+func TestParseLocalReceiptErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ParseLocalReceipt([]byte("invalid"))
+	assert.Equal(ErrInvalidPKCS7, err)
+
+	data := testLocalReceiptData(t, testGUID, false)
+	_, err = ParseLocalReceipt(data[:len(data)-10])
+	assert.Equal(ErrInvalidPKCS7, err)
+
+	// not signed data
+	_, err = ParseLocalReceipt(testTLV(0x30, false, testMarshal(t, oidData)))
+	assert.Equal(ErrInvalidPKCS7, err)
+}
+
+func TestValidateLocalReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestReceiptSigner(t, testRSAKey(t), true)
+	data := signer.sign(t, testLocalReceiptPayload(t, testGUID, "1.2.0"), crypto.SHA1, false)
+
+	r, err := ValidateLocalReceipt(data, testGUID, "com.example.macapp", "1.2.0", signer.roots)
+	assert.NoError(err)
+	assert.Equal("com.example.product.sub", r.InApps[0].ProductID)
+
+	_, err = ValidateLocalReceipt(data, testGUID, "com.example.macapp", "", signer.roots)
+	assert.NoError(err)
+
+	_, err = ValidateLocalReceipt(data, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, "com.example.macapp", "1.2.0", signer.roots)
+	assert.Equal(ErrReceiptHashMismatch, err)
+
+	_, err = ValidateLocalReceipt(data, testGUID, "com.example.other", "1.2.0", signer.roots)
+	assert.Equal(ErrReceiptBundleIDMismatch, err)
+
+	_, err = ValidateLocalReceipt(data, testGUID, "com.example.macapp", "1.3.0", signer.roots)
+	assert.Equal(ErrReceiptVersionMismatch, err)
+
+	_, err = ValidateLocalReceipt(data, testGUID, "com.example.macapp", "1.2.0", nil)
+	assert.Equal(ErrNoRootCertificates, err)
+}
+
+func TestVerifyLocalReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := testLocalReceiptPayload(t, testGUID, "1.2.0")
+	rsaSigner := newTestReceiptSigner(t, testRSAKey(t), true)
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecdsaSigner := newTestReceiptSigner(t, ecdsaKey, true)
+
+	tests := []struct {
+		signer         *testReceiptSigner
+		hash           crypto.Hash
+		withAttributes bool
+	}{
+		{rsaSigner, crypto.SHA1, false},
+		{rsaSigner, crypto.SHA256, false},
+		{rsaSigner, crypto.SHA256, true},
+		{ecdsaSigner, crypto.SHA256, true},
+	}
+	for _, tt := range tests {
+		r, err := VerifyLocalReceipt(tt.signer.sign(t, payload, tt.hash, tt.withAttributes), tt.signer.roots)
+		assert.NoError(err)
+		assert.Equal("1.2.0", r.ApplicationVersion)
+	}
+}
+
+func TestVerifyLocalReceiptErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestReceiptSigner(t, testRSAKey(t), true)
+	payload := testLocalReceiptPayload(t, testGUID, "1.2.0")
+
+	// unsigned container built by anyone who knows the device GUID
+	_, err := VerifyLocalReceipt(testLocalReceiptData(t, testGUID, false), signer.roots)
+	assert.Equal(ErrInvalidPKCS7Signature, err)
+
+	// re-signed with the certificate chain which is not under the root
+	other := newTestReceiptSigner(t, testRSAKey(t), true)
+	_, err = VerifyLocalReceipt(other.sign(t, payload, crypto.SHA256, true), signer.roots)
+	assert.Error(err)
+
+	// re-signed with the trusted chain without the extensions of Apple
+	noExt := newTestReceiptSigner(t, testRSAKey(t), false)
+	_, err = VerifyLocalReceipt(noExt.sign(t, payload, crypto.SHA256, true), noExt.roots)
+	assert.Equal(ErrInvalidPKCS7Signature, err)
+
+	// tampered content after signing
+	for _, withAttributes := range []bool{false, true} {
+		data := signer.sign(t, payload, crypto.SHA256, withAttributes)
+		tampered := bytes.Replace(data, []byte("1.2.0"), []byte("9.9.9"), 1)
+		assert.NotEqual(data, tampered)
+		_, err = VerifyLocalReceipt(tampered, signer.roots)
+		assert.Equal(ErrInvalidPKCS7Signature, err)
+	}
+
+	// the signature of the other content
+	data := signer.sign(t, testLocalReceiptPayload(t, testGUID, "9.9.9"), crypto.SHA1, false)
+	resigned := bytes.Replace(data, []byte("9.9.9"), []byte("1.2.0"), 1)
+	_, err = VerifyLocalReceipt(resigned, signer.roots)
+	assert.Equal(ErrInvalidPKCS7Signature, err)
+}
+
+func TestReadBERDepth(t *testing.T) {
+	assert := assert.New(t)
+
+	data := []byte{0x04, 0x00}
+	for i := 0; i < maxBERDepth; i++ {
+		data = testTLV(0x30, true, data)
+	}
+	_, err := berToDER(data)
+	assert.NoError(err)
+
+	_, err = berToDER(testTLV(0x30, true, data))
+	assert.Error(err)
+
+	// hostile input must not exhaust the stack
+	_, err = ParseLocalReceipt(bytes.Repeat([]byte{0x30, 0x80}, 1<<20))
+	assert.Equal(ErrInvalidPKCS7, err)
+}
+
+func TestReadBERLength(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := [][]byte{
+		{0x04, 0x84, 0xff, 0xff, 0xff, 0xff, 0x00},
+		{0x04, 0x84, 0x80, 0x00, 0x00, 0x00, 0x00},
+		{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+		{0x30, 0x84, 0xff, 0xff, 0xff, 0xfe, 0x04, 0x00},
+		{0x04, 0x85, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x04, 0x82, 0x01},
+		{0x04, 0x81, 0x02, 0x00},
+	}
+	for _, data := range tests {
+		_, err := berToDER(data)
+		assert.Error(err, "% x", data)
+	}
+
+	// the length of all sizes from the random bytes must not panic
+	for i := 0; i < 0x100; i++ {
+		data := []byte{0x30, 0x84, byte(i), byte(i * 7), byte(i * 13), byte(i * 31), 0x04, 0x00}
+		assert.NotPanics(func() { berToDER(data) })
+	}
+
+	_, err := berToDER([]byte{0x04, 0x82, 0x00, 0x01, 0xaa})
+	assert.NoError(err)
+}
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -28,6 +28,13 @@ type (
 		OriginalPurchaseDatePST string `json:"original_purchase_date_pst"`
 	}
 
+	// The ReceiptCreationDate type indicates the date when the app receipt was created
+	ReceiptCreationDate struct {
+		ReceiptCreationDate    string `json:"receipt_creation_date"`
+		ReceiptCreationDateMS  string `json:"receipt_creation_date_ms"`
+		ReceiptCreationDatePST string `json:"receipt_creation_date_pst"`
+	}
+
 	// The ExpiresDate type indicates the expiration date for the subscription
 	ExpiresDate struct {
 		ExpiresDate    string `json:"expires_date"`
@@ -26,6 +26,7 @@ func (r *IAPResponseIOS7) ToReceipt() *Receipt {
 		rawReceipt:                 r.rawReceipt,
 		Status:                     r.Status,
 		Environment:                r.Environment,
+		IsRetryable:                r.IsRetryable,
 		ReceiptType:                rr.ReceiptType,
 		AdamID:                     rr.AdamID,
 		AppItemID:                  rr.AppItemID,
@@ -35,6 +36,7 @@ func (r *IAPResponseIOS7) ToReceipt() *Receipt {
 		OriginalApplicationVersion: rr.OriginalApplicationVersion,
 		RequestDate:                ToTime(rr.RequestDate.RequestDateMS),
 		OriginalPurchaseDate:       ToTime(rr.OriginalPurchaseDate.OriginalPurchaseDateMS),
+		ReceiptCreationDate:        ToTime(rr.ReceiptCreationDate.ReceiptCreationDateMS),
 		LatestReceipt:              r.LatestReceipt,
 	}
 	receipt.InApps = ToReceiptInApps(rr.InApp)
@@ -55,6 +57,7 @@ type ReceiptIOS7 struct {
 	InApp                      []InApp `json:"in_app"`
 	RequestDate
 	OriginalPurchaseDate
+	ReceiptCreationDate
 }
 
 // The InApp type has the receipt attributes
@@ -68,6 +71,7 @@ type InApp struct {
 	AppItemID                 string `json:"app_item_id"`
 	VersionExternalIdentifier string `json:"version_external_identifier"`
 	WebOrderLineItemID        string `json:"web_order_line_item_id"`
+	InAppOwnershipType        string `json:"in_app_ownership_type"`
 	PurchaseDate
 	OriginalPurchaseDate
 	ExpiresDate
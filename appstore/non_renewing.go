@@ -0,0 +1,105 @@
+package appstore
+
+import (
+	"sort"
+	"time"
+)
+
+// NonRenewingCatalog is the durations of non-renewing subscriptions by product id.
+// The receipt doesn't have `expires_date` of non-renewing subscriptions, so the app defines the durations.
+// The products are ProductTypeNonRenewing in ProductCatalog.
+type NonRenewingCatalog map[string]time.Duration
+
+// Duration returns the duration of the product, or zero when it's not in the catalog
+func (c NonRenewingCatalog) Duration(productID string) time.Duration {
+	return c[productID]
+}
+
+// ProductCatalog returns the catalog of the products as ProductTypeNonRenewing,
+// so that ProductCatalog is built from the same definition.
+func (c NonRenewingCatalog) ProductCatalog() ProductCatalog {
+	catalog := make(ProductCatalog, len(c))
+	for id := range c {
+		catalog[id] = ProductTypeNonRenewing
+	}
+	return catalog
+}
+
+// EntitlementWindow is the contiguous period given by the consecutive purchases of non-renewing subscriptions
+type EntitlementWindow struct {
+	Start time.Time
+	End   time.Time
+	// Purchases are the purchases stacked into the window ordered by purchase date
+	Purchases ReceiptInApps
+}
+
+// IsActiveAt checks the window includes the time or not
+func (w *EntitlementWindow) IsActiveAt(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Windows stacks the purchases of the products in the catalog into the entitlement windows ordered by time.
+// The purchase made before the end of the current window extends it by the duration of the product,
+// and the purchase after that starts a new window.
+// Refunded purchases are treated as if they had never been made, so the later purchases move forward.
+// The same transaction_id is stacked only once, so `in_app` and `latest_receipt_info` can be passed together,
+// and it's treated as refunded when either of them has `cancellation_date`.
+func (c NonRenewingCatalog) Windows(purchases ReceiptInApps) []EntitlementWindow {
+	// the refund in either list is applied
+	seen := make(map[int64]bool)
+	for _, v := range purchases {
+		if v.IsRefunded() {
+			seen[v.TransactionID] = true
+		}
+	}
+	var list ReceiptInApps
+	for _, v := range purchases {
+		if seen[v.TransactionID] {
+			continue
+		}
+		seen[v.TransactionID] = true
+		if c.Duration(v.ProductID) > 0 {
+			list = append(list, v)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].PurchaseDate.Before(list[j].PurchaseDate)
+	})
+
+	var windows []EntitlementWindow
+	for _, v := range list {
+		d := c.Duration(v.ProductID)
+		if n := len(windows); n > 0 && v.PurchaseDate.Before(windows[n-1].End) {
+			w := &windows[n-1]
+			w.End = w.End.Add(d)
+			w.Purchases = append(w.Purchases, v)
+			continue
+		}
+		windows = append(windows, EntitlementWindow{
+			Start:     v.PurchaseDate,
+			End:       v.PurchaseDate.Add(d),
+			Purchases: ReceiptInApps{v},
+		})
+	}
+	return windows
+}
+
+// WindowAt returns the entitlement window which includes the time, or nil when the user is not entitled
+func (c NonRenewingCatalog) WindowAt(purchases ReceiptInApps, t time.Time) *EntitlementWindow {
+	windows := c.Windows(purchases)
+	for i := range windows {
+		if windows[i].IsActiveAt(t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// NonRenewingExpiresAt returns the end of the entitlement of non-renewing subscriptions in the catalog at the time.
+// It returns zero time when the user is not entitled.
+func (r *Receipt) NonRenewingExpiresAt(catalog NonRenewingCatalog, t time.Time) time.Time {
+	if w := catalog.WindowAt(r.InApps, t); w != nil {
+		return w.End
+	}
+	return time.Time{}
+}
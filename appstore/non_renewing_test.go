@@ -0,0 +1,88 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testNonRenewingCatalog = NonRenewingCatalog{
+	"season":  30 * 24 * time.Hour,
+	"weekend": 2 * 24 * time.Hour,
+}
+
+func testNonRenewingInApp(id int64, productID string, day int) *ReceiptInApp {
+	return &ReceiptInApp{
+		TransactionID: id,
+		ProductID:     productID,
+		PurchaseDate:  testDiffStart.AddDate(0, 0, day),
+	}
+}
+
+func TestNonRenewingWindows(t *testing.T) {
+	assert := assert.New(t)
+
+	day := func(d int) time.Time { return testDiffStart.AddDate(0, 0, d) }
+	purchases := ReceiptInApps{
+		testNonRenewingInApp(3, "weekend", 40),
+		testNonRenewingInApp(1, "season", 0),
+		testNonRenewingInApp(2, "season", 10),
+		testNonRenewingInApp(4, "coin", 50),
+		testNonRenewingInApp(5, "weekend", 100),
+	}
+
+	windows := testNonRenewingCatalog.Windows(purchases)
+	assert.Len(windows, 2)
+	assert.Equal(day(0), windows[0].Start)
+	assert.Equal(day(62), windows[0].End)
+	assert.Equal([]int64{1, 2, 3}, windows[0].Purchases.TransactionIDs())
+	assert.Equal(day(100), windows[1].Start)
+	assert.Equal(day(102), windows[1].End)
+
+	assert.Nil(testNonRenewingCatalog.WindowAt(purchases, day(-1)))
+	assert.Equal(day(0), testNonRenewingCatalog.WindowAt(purchases, day(61)).Start)
+	assert.Nil(testNonRenewingCatalog.WindowAt(purchases, day(62)))
+	assert.Equal(day(100), testNonRenewingCatalog.WindowAt(purchases, day(101)).Start)
+	assert.Empty(NonRenewingCatalog(nil).Windows(purchases))
+
+	// in_app and latest_receipt_info are passed together
+	duplicated := append(ReceiptInApps{}, purchases...)
+	duplicated = append(duplicated, testNonRenewingInApp(1, "season", 0), testNonRenewingInApp(3, "weekend", 40))
+	assert.Equal(windows, testNonRenewingCatalog.Windows(duplicated))
+}
+
+func TestNonRenewingProductCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(ProductCatalog{
+		"season":  ProductTypeNonRenewing,
+		"weekend": ProductTypeNonRenewing,
+	}, testNonRenewingCatalog.ProductCatalog())
+}
+
+func TestNonRenewingWindowsWithRefund(t *testing.T) {
+	assert := assert.New(t)
+
+	refunded := testNonRenewingInApp(1, "season", 0)
+	refunded.CancellationDate = testDiffStart.AddDate(0, 0, 5)
+	r := &Receipt{
+		InApps: ReceiptInApps{
+			refunded,
+			testNonRenewingInApp(2, "season", 10),
+		},
+	}
+
+	windows := testNonRenewingCatalog.Windows(r.InApps)
+	assert.Len(windows, 1)
+	assert.Equal([]int64{2}, windows[0].Purchases.TransactionIDs())
+
+	// the copy without cancellation_date is not stacked again
+	duplicated := append(ReceiptInApps{}, r.InApps...)
+	duplicated = append(duplicated, testNonRenewingInApp(1, "season", 0))
+	assert.Equal(windows, testNonRenewingCatalog.Windows(duplicated))
+	duplicated = append(ReceiptInApps{testNonRenewingInApp(1, "season", 0)}, r.InApps...)
+	assert.Equal(windows, testNonRenewingCatalog.Windows(duplicated))
+	assert.True(r.NonRenewingExpiresAt(testNonRenewingCatalog, testDiffStart.AddDate(0, 0, 5)).IsZero())
+	assert.Equal(testDiffStart.AddDate(0, 0, 40), r.NonRenewingExpiresAt(testNonRenewingCatalog, testDiffStart.AddDate(0, 0, 39)))
+}
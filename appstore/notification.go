@@ -0,0 +1,118 @@
+package appstore
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// NotificationType is notification_type of App Store Server Notifications V1
+// see: https://developer.apple.com/documentation/appstoreservernotifications/notification_type
+type NotificationType string
+
+const (
+	NotificationTypeCancel                 NotificationType = "CANCEL"
+	NotificationTypeConsumptionRequest     NotificationType = "CONSUMPTION_REQUEST"
+	NotificationTypeDidChangeRenewalPref   NotificationType = "DID_CHANGE_RENEWAL_PREF"
+	NotificationTypeDidChangeRenewalStatus NotificationType = "DID_CHANGE_RENEWAL_STATUS"
+	NotificationTypeDidFailToRenew         NotificationType = "DID_FAIL_TO_RENEW"
+	NotificationTypeDidRecover             NotificationType = "DID_RECOVER"
+	NotificationTypeDidRenew               NotificationType = "DID_RENEW"
+	NotificationTypeInitialBuy             NotificationType = "INITIAL_BUY"
+	NotificationTypeInteractiveRenewal     NotificationType = "INTERACTIVE_RENEWAL"
+	NotificationTypePriceIncreaseConsent   NotificationType = "PRICE_INCREASE_CONSENT"
+	NotificationTypeRefund                 NotificationType = "REFUND"
+	NotificationTypeRevoke                 NotificationType = "REVOKE"
+	NotificationTypeRenewal                NotificationType = "RENEWAL"
+)
+
+var knownNotificationTypes = map[NotificationType]bool{
+	NotificationTypeCancel:                 true,
+	NotificationTypeConsumptionRequest:     true,
+	NotificationTypeDidChangeRenewalPref:   true,
+	NotificationTypeDidChangeRenewalStatus: true,
+	NotificationTypeDidFailToRenew:         true,
+	NotificationTypeDidRecover:             true,
+	NotificationTypeDidRenew:               true,
+	NotificationTypeInitialBuy:             true,
+	NotificationTypeInteractiveRenewal:     true,
+	NotificationTypePriceIncreaseConsent:   true,
+	NotificationTypeRefund:                 true,
+	NotificationTypeRevoke:                 true,
+	NotificationTypeRenewal:                true,
+}
+
+// IsKnown checks the notification type is documented by Apple or not
+func (t NotificationType) IsKnown() bool {
+	return knownNotificationTypes[t]
+}
+
+// ErrInvalidNotificationPassword is returned when the password of the notification
+// does not match the shared secret
+var ErrInvalidNotificationPassword = errors.New("The password in the notification does not match the shared secret.")
+
+// ErrEmptySharedSecret is returned when the shared secret to check the notification is empty
+var ErrEmptySharedSecret = errors.New("The shared secret to check the notification is empty.")
+
+// Notification is the request body of App Store Server Notifications V1
+type Notification struct {
+	NotificationType   NotificationType `json:"notification_type"`
+	Password           string           `json:"password"`
+	Environment        string           `json:"environment"`
+	AutoRenewAdamID    string           `json:"auto_renew_adam_id"`
+	AutoRenewProductID string           `json:"auto_renew_product_id"`
+	AutoRenewStatus    string           `json:"auto_renew_status"`
+	ExpirationIntent   string           `json:"expiration_intent"`
+	BundleID           string           `json:"bid"`
+	BundleVersion      string           `json:"bvrs"`
+
+	AutoRenewStatusChangeDate    string `json:"auto_renew_status_change_date"`
+	AutoRenewStatusChangeDateMS  string `json:"auto_renew_status_change_date_ms"`
+	AutoRenewStatusChangeDatePST string `json:"auto_renew_status_change_date_pst"`
+
+	OriginalTransactionID json.Number `json:"original_transaction_id"`
+
+	// unified_receipt has the same shape as verifyReceipt response
+	UnifiedReceipt IAPResponseIOS7 `json:"unified_receipt"`
+}
+
+// ParseNotification parses the body of App Store Server Notifications V1
+// and checks its password is the given shared secret.
+// The empty shared secret is rejected, otherwise the notification without password would be accepted.
+func ParseNotification(body []byte, password string) (*Notification, error) {
+	if password == "" {
+		return nil, ErrEmptySharedSecret
+	}
+
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(n.Password), []byte(password)) != 1 {
+		return nil, ErrInvalidNotificationPassword
+	}
+	return &n, nil
+}
+
+// IsAutoRenewStatusOn checks `auto_renew_status` is enabled or not
+func (n *Notification) IsAutoRenewStatusOn() bool {
+	return ToBool(n.AutoRenewStatus)
+}
+
+// GetAutoRenewStatusChangeDate returns `auto_renew_status_change_date`
+func (n *Notification) GetAutoRenewStatusChangeDate() time.Time {
+	return ToTime(n.AutoRenewStatusChangeDateMS)
+}
+
+// GetOriginalTransactionID returns `original_transaction_id`
+func (n *Notification) GetOriginalTransactionID() int64 {
+	return ToInt64(n.OriginalTransactionID.String())
+}
+
+// ToReceipt converts `unified_receipt` into Receipt
+func (n *Notification) ToReceipt() *Receipt {
+	ur := n.UnifiedReceipt
+	ur.rawReceipt = ur.LatestReceipt
+	return ur.ToReceipt()
+}
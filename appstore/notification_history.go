@@ -0,0 +1,137 @@
+package appstore
+
+import (
+	"net/url"
+)
+
+// SendAttempt is the result of an attempt to send a notification to your server
+type SendAttempt struct {
+	AttemptDate       int64  `json:"attemptDate"`
+	SendAttemptResult string `json:"sendAttemptResult"`
+}
+
+// TestNotificationStatus is the status of the test notification
+// see: https://developer.apple.com/documentation/appstoreserverapi/checktestnotificationresponse
+type TestNotificationStatus struct {
+	Notification           *NotificationV2
+	FirstSendAttemptResult string
+	SendAttempts           []SendAttempt
+}
+
+// NotificationHistoryRequest is the request body to get notification history.
+// StartDate and EndDate are milliseconds since epoch.
+// see: https://developer.apple.com/documentation/appstoreserverapi/notificationhistoryrequest
+type NotificationHistoryRequest struct {
+	StartDate           int64                 `json:"startDate"`
+	EndDate             int64                 `json:"endDate"`
+	NotificationType    NotificationTypeV2    `json:"notificationType,omitempty"`
+	NotificationSubtype NotificationSubtypeV2 `json:"notificationSubtype,omitempty"`
+	TransactionID       string                `json:"transactionId,omitempty"`
+	OnlyFailures        bool                  `json:"onlyFailures,omitempty"`
+}
+
+// NotificationHistoryItem is a notification in the history with its attempts
+type NotificationHistoryItem struct {
+	Notification *NotificationV2
+	SendAttempts []SendAttempt
+}
+
+// NotificationHistoryPage is a page of notification history
+type NotificationHistoryPage struct {
+	Items           []NotificationHistoryItem
+	HasMore         bool
+	PaginationToken string
+}
+
+// RequestTestNotification asks the App Store to send a test notification to your server
+// and returns the token to check its status.
+// see: https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
+func (c *ServerAPIClient) RequestTestNotification() (string, error) {
+	var result struct {
+		TestNotificationToken string `json:"testNotificationToken"`
+	}
+	if err := c.do(post, "/inApps/v1/notifications/test", nil, &result); err != nil {
+		return "", err
+	}
+	return result.TestNotificationToken, nil
+}
+
+// GetTestNotificationStatus returns the status of the test notification
+// see: https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
+func (c *ServerAPIClient) GetTestNotificationStatus(token string) (*TestNotificationStatus, error) {
+	var result struct {
+		SignedPayload          string        `json:"signedPayload"`
+		FirstSendAttemptResult string        `json:"firstSendAttemptResult"`
+		SendAttempts           []SendAttempt `json:"sendAttempts"`
+	}
+	if err := c.do(get, "/inApps/v1/notifications/test/"+url.PathEscape(token), nil, &result); err != nil {
+		return nil, err
+	}
+
+	n, err := ParseSignedNotificationV2(result.SignedPayload, c.roots)
+	if err != nil {
+		return nil, err
+	}
+	return &TestNotificationStatus{
+		Notification:           n,
+		FirstSendAttemptResult: result.FirstSendAttemptResult,
+		SendAttempts:           result.SendAttempts,
+	}, nil
+}
+
+// GetNotificationHistory returns a page of notifications sent to your server.
+// Set paginationToken from the previous page to get the next page.
+// see: https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
+func (c *ServerAPIClient) GetNotificationHistory(req NotificationHistoryRequest, paginationToken string) (*NotificationHistoryPage, error) {
+	path := "/inApps/v1/notifications/history"
+	if paginationToken != "" {
+		path += "?paginationToken=" + url.QueryEscape(paginationToken)
+	}
+
+	var result struct {
+		NotificationHistory []struct {
+			SignedPayload string        `json:"signedPayload"`
+			SendAttempts  []SendAttempt `json:"sendAttempts"`
+		} `json:"notificationHistory"`
+		HasMore         bool   `json:"hasMore"`
+		PaginationToken string `json:"paginationToken"`
+	}
+	if err := c.do(post, path, req, &result); err != nil {
+		return nil, err
+	}
+
+	page := &NotificationHistoryPage{
+		HasMore:         result.HasMore,
+		PaginationToken: result.PaginationToken,
+	}
+	for _, v := range result.NotificationHistory {
+		n, err := ParseSignedNotificationV2(v.SignedPayload, c.roots)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, NotificationHistoryItem{
+			Notification: n,
+			SendAttempts: v.SendAttempts,
+		})
+	}
+	return page, nil
+}
+
+// GetAllNotificationHistory returns all of notifications sent to your server through the pages
+func (c *ServerAPIClient) GetAllNotificationHistory(req NotificationHistoryRequest) ([]*NotificationV2, error) {
+	var list []*NotificationV2
+	token := ""
+	for {
+		page, err := c.GetNotificationHistory(req, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Items {
+			list = append(list, v.Notification)
+		}
+		if !page.HasMore || page.PaginationToken == "" {
+			return list, nil
+		}
+		token = page.PaginationToken
+	}
+}
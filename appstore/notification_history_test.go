@@ -0,0 +1,97 @@
+package appstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestNotification(t *testing.T) {
+	assert := assert.New(t)
+
+	var signer *testSigner
+	server, client, signer := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/inApps/v1/notifications/test":
+			fmt.Fprint(w, `{"testNotificationToken":"token-1"}`)
+		case r.Method == "GET" && r.URL.Path == "/inApps/v1/notifications/test/token-1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"signedPayload":          signer.sign(t, map[string]interface{}{"notificationType": "TEST", "notificationUUID": "uuid-test"}),
+				"firstSendAttemptResult": "SUCCESS",
+				"sendAttempts":           []map[string]interface{}{{"attemptDate": 1582000000000, "sendAttemptResult": "SUCCESS"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	token, err := client.RequestTestNotification()
+	assert.NoError(err)
+	assert.Equal("token-1", token)
+
+	status, err := client.GetTestNotificationStatus(token)
+	assert.NoError(err)
+	assert.Equal(NotificationTypeV2Test, status.Notification.NotificationType)
+	assert.Equal("SUCCESS", status.FirstSendAttemptResult)
+	assert.Len(status.SendAttempts, 1)
+
+	_, err = client.GetTestNotificationStatus("unknown")
+	assert.Error(err)
+}
+
+func TestGetNotificationHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	var signer *testSigner
+	server, client, signer := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		assert.Equal("/inApps/v1/notifications/history", r.URL.Path)
+
+		var req NotificationHistoryRequest
+		assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(int64(1580000000000), req.StartDate)
+		assert.Equal(int64(1590000000000), req.EndDate)
+		assert.Equal(NotificationTypeV2DidRenew, req.NotificationType)
+
+		switch r.URL.Query().Get("paginationToken") {
+		case "":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"notificationHistory": []map[string]interface{}{
+					{"signedPayload": testSignedNotificationV2(t, signer, "uuid-1")},
+				},
+				"hasMore":         true,
+				"paginationToken": "page-2",
+			})
+		case "page-2":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"notificationHistory": []map[string]interface{}{
+					{"signedPayload": testSignedNotificationV2(t, signer, "uuid-2")},
+				},
+				"hasMore": false,
+			})
+		}
+	})
+	defer server.Close()
+
+	req := NotificationHistoryRequest{
+		StartDate:        1580000000000,
+		EndDate:          1590000000000,
+		NotificationType: NotificationTypeV2DidRenew,
+	}
+	page, err := client.GetNotificationHistory(req, "")
+	assert.NoError(err)
+	assert.True(page.HasMore)
+	assert.Equal("page-2", page.PaginationToken)
+	assert.Len(page.Items, 1)
+
+	list, err := client.GetAllNotificationHistory(req)
+	assert.NoError(err)
+	assert.Len(list, 2)
+	assert.Equal("uuid-1", list[0].NotificationUUID)
+	assert.Equal("uuid-2", list[1].NotificationUUID)
+	assert.Equal(int64(2000000002), list[1].Transaction.ToReceiptInApp().TransactionID)
+}
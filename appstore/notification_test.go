@@ -0,0 +1,96 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNotificationString = `{
+	"notification_type": "DID_RENEW",
+	"password": "secret",
+	"environment": "PROD",
+	"auto_renew_product_id": "com.example.product.sub",
+	"auto_renew_status": "true",
+	"auto_renew_status_change_date_ms": "1582000000000",
+	"bid": "com.example.app",
+	"bvrs": "1.2.0",
+	"original_transaction_id": 90000000000001,
+	"unified_receipt": {
+		"status": 0,
+		"environment": "Production",
+		"latest_receipt": "dummy_latest_receipt",
+		"latest_receipt_info": [
+			{
+				"quantity": "1",
+				"product_id": "com.example.product.sub",
+				"transaction_id": "90000000000002",
+				"original_transaction_id": "90000000000001",
+				"purchase_date_ms": "1582000000000",
+				"original_purchase_date_ms": "1579000000000",
+				"expires_date_ms": "1584600000000",
+				"web_order_line_item_id": "70000000000002",
+				"is_trial_period": "false"
+			}
+		],
+		"pending_renewal_info": [
+			{
+				"auto_renew_product_id": "com.example.product.sub",
+				"product_id": "com.example.product.sub",
+				"original_transaction_id": "90000000000001",
+				"auto_renew_status": "1"
+			}
+		]
+	}
+}`
+
+func TestParseNotification(t *testing.T) {
+	assert := assert.New(t)
+
+	n, err := ParseNotification([]byte(testNotificationString), "secret")
+	assert.NoError(err)
+	assert.Equal(NotificationTypeDidRenew, n.NotificationType)
+	assert.True(n.NotificationType.IsKnown())
+	assert.Equal("com.example.app", n.BundleID)
+	assert.True(n.IsAutoRenewStatusOn())
+	assert.Equal(time.Unix(1582000000, 0), n.GetAutoRenewStatusChangeDate())
+	assert.Equal(int64(90000000000001), n.GetOriginalTransactionID())
+
+	_, err = ParseNotification([]byte(testNotificationString), "wrong")
+	assert.Equal(ErrInvalidNotificationPassword, err)
+
+	_, err = ParseNotification([]byte("{"), "secret")
+	assert.Error(err)
+
+	_, err = ParseNotification([]byte(`{"notification_type":"DID_RENEW"}`), "")
+	assert.Equal(ErrEmptySharedSecret, err)
+}
+
+func TestNotificationType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(NotificationTypeInitialBuy.IsKnown())
+	assert.True(NotificationTypeCancel.IsKnown())
+	assert.False(NotificationType("DID_SOMETHING").IsKnown())
+}
+
+func TestNotificationToReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	n, err := ParseNotification([]byte(testNotificationString), "secret")
+	assert.NoError(err)
+
+	r := n.ToReceipt()
+	assert.Equal(0, r.Status)
+	assert.Equal("Production", r.Environment)
+	assert.Equal("dummy_latest_receipt", r.String())
+	assert.Equal("dummy_latest_receipt", r.LatestReceiptString())
+	assert.Len(r.InApps, 0)
+	assert.Len(r.LatestReceiptInfo, 1)
+
+	inApp := r.GetLastExpiresByProductID("com.example.product.sub")
+	assert.Equal(int64(90000000000002), inApp.TransactionID)
+	assert.Equal(time.Unix(1584600000, 0), inApp.ExpiresDate)
+	assert.True(r.PendingRenewalInfo.IsAutoRenewStatusOn("com.example.product.sub"))
+}
@@ -0,0 +1,158 @@
+package appstore
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"time"
+)
+
+// NotificationTypeV2 is notificationType of App Store Server Notifications V2
+// see: https://developer.apple.com/documentation/appstoreservernotifications/notificationtype
+type NotificationTypeV2 string
+
+const (
+	NotificationTypeV2ConsumptionRequest     NotificationTypeV2 = "CONSUMPTION_REQUEST"
+	NotificationTypeV2DidChangeRenewalPref   NotificationTypeV2 = "DID_CHANGE_RENEWAL_PREF"
+	NotificationTypeV2DidChangeRenewalStatus NotificationTypeV2 = "DID_CHANGE_RENEWAL_STATUS"
+	NotificationTypeV2DidFailToRenew         NotificationTypeV2 = "DID_FAIL_TO_RENEW"
+	NotificationTypeV2DidRenew               NotificationTypeV2 = "DID_RENEW"
+	NotificationTypeV2Expired                NotificationTypeV2 = "EXPIRED"
+	NotificationTypeV2GracePeriodExpired     NotificationTypeV2 = "GRACE_PERIOD_EXPIRED"
+	NotificationTypeV2OfferRedeemed          NotificationTypeV2 = "OFFER_REDEEMED"
+	NotificationTypeV2OneTimeCharge          NotificationTypeV2 = "ONE_TIME_CHARGE"
+	NotificationTypeV2PriceIncrease          NotificationTypeV2 = "PRICE_INCREASE"
+	NotificationTypeV2Refund                 NotificationTypeV2 = "REFUND"
+	NotificationTypeV2RefundDeclined         NotificationTypeV2 = "REFUND_DECLINED"
+	NotificationTypeV2RefundReversed         NotificationTypeV2 = "REFUND_REVERSED"
+	NotificationTypeV2RenewalExtended        NotificationTypeV2 = "RENEWAL_EXTENDED"
+	NotificationTypeV2RenewalExtension       NotificationTypeV2 = "RENEWAL_EXTENSION"
+	NotificationTypeV2Revoke                 NotificationTypeV2 = "REVOKE"
+	NotificationTypeV2Subscribed             NotificationTypeV2 = "SUBSCRIBED"
+	NotificationTypeV2Test                   NotificationTypeV2 = "TEST"
+)
+
+// NotificationSubtypeV2 is subtype of App Store Server Notifications V2
+// see: https://developer.apple.com/documentation/appstoreservernotifications/subtype
+type NotificationSubtypeV2 string
+
+const (
+	NotificationSubtypeV2InitialBuy        NotificationSubtypeV2 = "INITIAL_BUY"
+	NotificationSubtypeV2Resubscribe       NotificationSubtypeV2 = "RESUBSCRIBE"
+	NotificationSubtypeV2Downgrade         NotificationSubtypeV2 = "DOWNGRADE"
+	NotificationSubtypeV2Upgrade           NotificationSubtypeV2 = "UPGRADE"
+	NotificationSubtypeV2AutoRenewEnabled  NotificationSubtypeV2 = "AUTO_RENEW_ENABLED"
+	NotificationSubtypeV2AutoRenewDisabled NotificationSubtypeV2 = "AUTO_RENEW_DISABLED"
+	NotificationSubtypeV2Voluntary         NotificationSubtypeV2 = "VOLUNTARY"
+	NotificationSubtypeV2BillingRetry      NotificationSubtypeV2 = "BILLING_RETRY"
+	NotificationSubtypeV2PriceIncrease     NotificationSubtypeV2 = "PRICE_INCREASE"
+	NotificationSubtypeV2GracePeriod       NotificationSubtypeV2 = "GRACE_PERIOD"
+	NotificationSubtypeV2BillingRecovery   NotificationSubtypeV2 = "BILLING_RECOVERY"
+	NotificationSubtypeV2Pending           NotificationSubtypeV2 = "PENDING"
+	NotificationSubtypeV2Accepted          NotificationSubtypeV2 = "ACCEPTED"
+	NotificationSubtypeV2Summary           NotificationSubtypeV2 = "SUMMARY"
+	NotificationSubtypeV2Failure           NotificationSubtypeV2 = "FAILURE"
+)
+
+// NotificationV2 is the decoded payload of App Store Server Notifications V2
+// see: https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2decodedpayload
+type NotificationV2 struct {
+	NotificationType NotificationTypeV2    `json:"notificationType"`
+	Subtype          NotificationSubtypeV2 `json:"subtype"`
+	NotificationUUID string                `json:"notificationUUID"`
+	Version          string                `json:"version"`
+	SignedDate       int64                 `json:"signedDate"`
+	Data             NotificationDataV2    `json:"data"`
+
+	// decoded from Data.SignedTransactionInfo and Data.SignedRenewalInfo
+	Transaction *JWSTransaction `json:"-"`
+	RenewalInfo *JWSRenewalInfo `json:"-"`
+}
+
+// NotificationDataV2 is data field of App Store Server Notifications V2
+type NotificationDataV2 struct {
+	AppAppleID            int64  `json:"appAppleId"`
+	BundleID              string `json:"bundleId"`
+	BundleVersion         string `json:"bundleVersion"`
+	Environment           string `json:"environment"`
+	SignedTransactionInfo string `json:"signedTransactionInfo"`
+	SignedRenewalInfo     string `json:"signedRenewalInfo"`
+	Status                int32  `json:"status"`
+}
+
+// JWSRenewalInfo is the decoded payload of signed renewal information
+// see: https://developer.apple.com/documentation/appstoreserverapi/jwsrenewalinfodecodedpayload
+type JWSRenewalInfo struct {
+	OriginalTransactionID       string `json:"originalTransactionId"`
+	AutoRenewProductID          string `json:"autoRenewProductId"`
+	ProductID                   string `json:"productId"`
+	AutoRenewStatus             int32  `json:"autoRenewStatus"`
+	IsInBillingRetryPeriod      bool   `json:"isInBillingRetryPeriod"`
+	PriceIncreaseStatus         int32  `json:"priceIncreaseStatus"`
+	GracePeriodExpiresDate      int64  `json:"gracePeriodExpiresDate"`
+	ExpirationIntent            int32  `json:"expirationIntent"`
+	OfferIdentifier             string `json:"offerIdentifier"`
+	OfferType                   int32  `json:"offerType"`
+	SignedDate                  int64  `json:"signedDate"`
+	Environment                 string `json:"environment"`
+	RecentSubscriptionStartDate int64  `json:"recentSubscriptionStartDate"`
+	RenewalDate                 int64  `json:"renewalDate"`
+}
+
+// ToReceiptPendingRenewalInfo converts signed renewal information into ReceiptPendingRenewalInfo
+func (ri *JWSRenewalInfo) ToReceiptPendingRenewalInfo() *ReceiptPendingRenewalInfo {
+	return &ReceiptPendingRenewalInfo{
+		ExpirationIntent:   int64(ri.ExpirationIntent),
+		AutoRenewProductID: ri.AutoRenewProductID,
+		RetryFlag:          ri.IsInBillingRetryPeriod,
+		AutoRenewStatus:    ri.AutoRenewStatus == 1,
+		PriceConsentStatus: ri.PriceIncreaseStatus == 1,
+		ProductID:          ri.ProductID,
+	}
+}
+
+// ParseNotificationV2 parses the request body of App Store Server Notifications V2.
+// roots is required to verify the certificate chains in the signed payloads (see LoadRootCertificates),
+// because anyone can send the request to the endpoint.
+func ParseNotificationV2(body []byte, roots *x509.CertPool) (*NotificationV2, error) {
+	if roots == nil {
+		return nil, ErrNoRootCertificates
+	}
+
+	var req struct {
+		SignedPayload string `json:"signedPayload"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return ParseSignedNotificationV2(req.SignedPayload, roots)
+}
+
+// ParseSignedNotificationV2 verifies and decodes signedPayload of App Store Server Notifications V2
+// with the signed transaction and renewal information in it.
+func ParseSignedNotificationV2(signedPayload string, roots *x509.CertPool) (*NotificationV2, error) {
+	var n NotificationV2
+	if err := parseSignedPayload(signedPayload, roots, &n); err != nil {
+		return nil, err
+	}
+
+	if n.Data.SignedTransactionInfo != "" {
+		tx, err := ParseSignedTransaction(n.Data.SignedTransactionInfo, roots)
+		if err != nil {
+			return nil, err
+		}
+		n.Transaction = tx
+	}
+	if n.Data.SignedRenewalInfo != "" {
+		var ri JWSRenewalInfo
+		if err := parseSignedPayload(n.Data.SignedRenewalInfo, roots, &ri); err != nil {
+			return nil, err
+		}
+		n.RenewalInfo = &ri
+	}
+	return &n, nil
+}
+
+// GetSignedDate returns the date when the notification is signed
+func (n *NotificationV2) GetSignedDate() time.Time {
+	return msToTime(n.SignedDate)
+}
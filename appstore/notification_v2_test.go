@@ -0,0 +1,114 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSignedNotificationV2(t *testing.T, signer *testSigner, uuid string) string {
+	return signer.sign(t, map[string]interface{}{
+		"notificationType": "DID_RENEW",
+		"notificationUUID": uuid,
+		"version":          "2.0",
+		"signedDate":       1582000000000,
+		"data": map[string]interface{}{
+			"bundleId":    "com.example.app",
+			"environment": "Production",
+			"signedTransactionInfo": signer.sign(t, map[string]interface{}{
+				"transactionId":         "2000000002",
+				"originalTransactionId": "2000000000",
+				"productId":             "com.example.product.sub",
+				"expiresDate":           1584600000000,
+			}),
+			"signedRenewalInfo": signer.sign(t, map[string]interface{}{
+				"originalTransactionId":  "2000000000",
+				"productId":              "com.example.product.sub",
+				"autoRenewProductId":     "com.example.product.sub2",
+				"autoRenewStatus":        1,
+				"isInBillingRetryPeriod": true,
+			}),
+		},
+	})
+}
+
+func TestParseNotificationV2(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestSigner(t)
+	body, _ := json.Marshal(map[string]string{
+		"signedPayload": testSignedNotificationV2(t, signer, "uuid-1"),
+	})
+
+	n, err := ParseNotificationV2(body, signer.roots)
+	assert.NoError(err)
+	assert.Equal(NotificationTypeV2DidRenew, n.NotificationType)
+	assert.Equal("uuid-1", n.NotificationUUID)
+	assert.Equal("com.example.app", n.Data.BundleID)
+	assert.Equal(time.Unix(1582000000, 0), n.GetSignedDate())
+
+	inApp := n.Transaction.ToReceiptInApp()
+	assert.Equal(int64(2000000002), inApp.TransactionID)
+	assert.Equal(time.Unix(1584600000, 0), inApp.ExpiresDate)
+
+	info := n.RenewalInfo.ToReceiptPendingRenewalInfo()
+	assert.True(info.AutoRenewStatus)
+	assert.True(info.RetryFlag)
+	assert.True(info.IsDifferentAutoRenewProductID())
+
+	_, err = ParseNotificationV2(body, newTestSigner(t).roots)
+	assert.Error(err)
+
+	_, err = ParseNotificationV2([]byte(`{"signedPayload":"invalid"}`), signer.roots)
+	assert.Equal(ErrInvalidJWS, err)
+}
+
+func TestParseNotificationV2SelfSigned(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestSigner(t)
+
+	// the payload signed by the attacker with the self-signed certificate in x5c
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "Forged Leaf"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleStoreKitLeaf, Value: asn1.NullBytes}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forger := &testSigner{key: key, x5c: []string{base64.StdEncoding.EncodeToString(der)}}
+	body, _ := json.Marshal(map[string]string{
+		"signedPayload": testSignedNotificationV2(t, forger, "uuid-1"),
+	})
+
+	_, err = ParseNotificationV2(body, nil)
+	assert.Equal(ErrNoRootCertificates, err)
+	_, err = ParseNotificationV2(body, signer.roots)
+	assert.Error(err)
+
+	// the attacker's own chain is also rejected without the extensions of Apple
+	forger = newTestSignerWithExtensions(t, false)
+	body, _ = json.Marshal(map[string]string{
+		"signedPayload": testSignedNotificationV2(t, forger, "uuid-1"),
+	})
+	_, err = ParseNotificationV2(body, signer.roots)
+	assert.Error(err)
+	_, err = ParseNotificationV2(body, forger.roots)
+	assert.Equal(ErrInvalidJWSCertificate, err)
+}
@@ -0,0 +1,354 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1" // register the hash functions of the signature
+	_ "crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// maxBERDepth is the maximum depth of nested ASN.1 values in the receipt
+const maxBERDepth = 64
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+	// ErrInvalidPKCS7 is returned when the receipt is not PKCS#7 signed data
+	ErrInvalidPKCS7 = errors.New("The receipt is not PKCS#7 signed data.")
+	// ErrInvalidPKCS7Signature is returned when the signature of the receipt cannot be verified
+	ErrInvalidPKCS7Signature = errors.New("The signature of the receipt cannot be verified.")
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerialNumber
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7IssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// pkcs7 is the parsed PKCS#7 signed data
+type pkcs7 struct {
+	content      []byte
+	certificates []*x509.Certificate
+	signerInfos  []pkcs7SignerInfo
+}
+
+// parsePKCS7Content returns the content of PKCS#7 signed data.
+// Note that the signature of the signed data is not verified.
+func parsePKCS7Content(data []byte) ([]byte, error) {
+	p, err := parsePKCS7(data)
+	if err != nil {
+		return nil, err
+	}
+	return p.content, nil
+}
+
+func parsePKCS7(data []byte) (*pkcs7, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, ErrInvalidPKCS7
+	}
+
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil || !info.ContentType.Equal(oidSignedData) {
+		return nil, ErrInvalidPKCS7
+	}
+	var signed pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil || !signed.ContentInfo.ContentType.Equal(oidData) {
+		return nil, ErrInvalidPKCS7
+	}
+	p := &pkcs7{}
+	if _, err := asn1.Unmarshal(signed.ContentInfo.Content.Bytes, &p.content); err != nil {
+		return nil, ErrInvalidPKCS7
+	}
+	if len(signed.Certificates.Bytes) != 0 {
+		if p.certificates, err = x509.ParseCertificates(signed.Certificates.Bytes); err != nil {
+			return nil, ErrInvalidPKCS7
+		}
+	}
+	if _, err := asn1.UnmarshalWithParams(signed.SignerInfos.FullBytes, &p.signerInfos, "set"); err != nil {
+		return nil, ErrInvalidPKCS7
+	}
+	return p, nil
+}
+
+// verify checks the signature of the signed data with the certificate chain issued by Apple under roots.
+// The chain is verified at the given time, which should be the creation date of the receipt
+// because the certificates of old receipts may be expired.
+func (p *pkcs7) verify(roots *x509.CertPool, at time.Time) error {
+	if roots == nil {
+		return ErrNoRootCertificates
+	}
+	if len(p.signerInfos) != 1 {
+		return ErrInvalidPKCS7Signature
+	}
+	si := p.signerInfos[0]
+
+	var signer *x509.Certificate
+	others := make([]*x509.Certificate, 0, len(p.certificates))
+	for _, cert := range p.certificates {
+		if signer == nil && bytes.Equal(cert.RawIssuer, si.IssuerAndSerialNumber.Issuer.FullBytes) &&
+			cert.SerialNumber.Cmp(si.IssuerAndSerialNumber.SerialNumber) == 0 {
+			signer = cert
+			continue
+		}
+		others = append(others, cert)
+	}
+	if signer == nil {
+		return ErrInvalidPKCS7Signature
+	}
+	if err := verifyAppleChain(append([]*x509.Certificate{signer}, others...), roots, at); err != nil {
+		if err == errNotAppleCertificate {
+			return ErrInvalidPKCS7Signature
+		}
+		return err
+	}
+
+	var hash crypto.Hash
+	switch {
+	case si.DigestAlgorithm.Algorithm.Equal(oidSHA1):
+		hash = crypto.SHA1
+	case si.DigestAlgorithm.Algorithm.Equal(oidSHA256):
+		hash = crypto.SHA256
+	default:
+		return ErrInvalidPKCS7Signature
+	}
+
+	// the signature is made over the authenticated attributes including the digest of the content when they exist
+	signed := p.content
+	if len(si.AuthenticatedAttributes.FullBytes) != 0 {
+		digest, err := si.messageDigest()
+		if err != nil {
+			return err
+		}
+		h := hash.New()
+		h.Write(p.content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return ErrInvalidPKCS7Signature
+		}
+		// the attributes are signed in DER encoding of SET OF, instead of the implicit tag
+		signed = append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+	h := hash.New()
+	h.Write(signed)
+	if !verifySignature(signer.PublicKey, hash, h.Sum(nil), si.EncryptedDigest) {
+		return ErrInvalidPKCS7Signature
+	}
+	return nil
+}
+
+// messageDigest returns the digest of the content in the authenticated attributes
+func (si pkcs7SignerInfo) messageDigest() ([]byte, error) {
+	rest := si.AuthenticatedAttributes.Bytes
+	for len(rest) > 0 {
+		var attr pkcs7Attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return nil, ErrInvalidPKCS7Signature
+		}
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, ErrInvalidPKCS7Signature
+		}
+		return digest, nil
+	}
+	return nil, ErrInvalidPKCS7Signature
+}
+
+// verifySignature verifies the signature of RSA (PKCS #1 v1.5) or ECDSA over the digest
+func verifySignature(pub crypto.PublicKey, hash crypto.Hash, digest, sig []byte) bool {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		var v struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &v); err != nil || len(rest) != 0 {
+			return false
+		}
+		return ecdsa.Verify(key, digest, v.R, v.S)
+	}
+	return false
+}
+
+// berToDER converts BER encoding into DER encoding,
+// which supports indefinite length and constructed OCTET STRING.
+func berToDER(data []byte) ([]byte, error) {
+	node, rest, err := readBER(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after ASN.1 value")
+	}
+	return node.encode(), nil
+}
+
+type berNode struct {
+	tag         []byte
+	constructed bool
+	value       []byte
+	children    []*berNode
+}
+
+func readBER(data []byte, depth int) (*berNode, []byte, error) {
+	if depth > maxBERDepth {
+		return nil, nil, errors.New("ASN.1 value is nested too deeply")
+	}
+	if len(data) < 2 {
+		return nil, nil, errors.New("ASN.1 value is too short")
+	}
+
+	// tag
+	i := 1
+	if data[0]&0x1f == 0x1f {
+		for ; i < len(data) && data[i]&0x80 != 0; i++ {
+		}
+		i++
+	}
+	if i >= len(data) {
+		return nil, nil, errors.New("ASN.1 tag is too long")
+	}
+	node := &berNode{
+		tag:         data[:i],
+		constructed: data[0]&0x20 != 0,
+	}
+
+	// length
+	l := int(data[i])
+	i++
+	indefinite := false
+	switch {
+	case l == 0x80:
+		indefinite = true
+	case l > 0x80:
+		n := l & 0x7f
+		if n > 4 || i+n > len(data) {
+			return nil, nil, errors.New("ASN.1 length is too long")
+		}
+		// uint64 doesn't overflow with 4 bytes even on 32-bit platforms
+		var length uint64
+		for _, b := range data[i : i+n] {
+			length = length<<8 | uint64(b)
+		}
+		i += n
+		if length > uint64(len(data)-i) {
+			return nil, nil, errors.New("ASN.1 value is truncated")
+		}
+		l = int(length)
+	}
+
+	body := data[i:]
+	if !indefinite {
+		if l > len(body) {
+			return nil, nil, errors.New("ASN.1 value is truncated")
+		}
+		body, data = body[:l], body[l:]
+	}
+
+	if !node.constructed {
+		if indefinite {
+			return nil, nil, errors.New("primitive ASN.1 value has indefinite length")
+		}
+		node.value = body
+		return node, data, nil
+	}
+
+	for {
+		if indefinite {
+			if len(body) >= 2 && body[0] == 0 && body[1] == 0 {
+				data = body[2:]
+				break
+			}
+		} else if len(body) == 0 {
+			break
+		}
+		child, rest, err := readBER(body, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.children = append(node.children, child)
+		body = rest
+	}
+
+	// constructed OCTET STRING is converted into primitive one
+	if len(node.tag) == 1 && node.tag[0] == 0x24 {
+		node.tag = []byte{0x04}
+		node.constructed = false
+		for _, v := range node.children {
+			node.value = append(node.value, v.flatValue()...)
+		}
+		node.children = nil
+	}
+	return node, data, nil
+}
+
+func (n *berNode) flatValue() []byte {
+	if !n.constructed {
+		return n.value
+	}
+	var b []byte
+	for _, v := range n.children {
+		b = append(b, v.encode()...)
+	}
+	return b
+}
+
+func (n *berNode) encode() []byte {
+	value := n.flatValue()
+	b := append([]byte{}, n.tag...)
+	b = append(b, encodeLength(len(value))...)
+	return append(b, value...)
+}
+
+func encodeLength(l int) []byte {
+	if l < 0x80 {
+		return []byte{byte(l)}
+	}
+	var b []byte
+	for ; l > 0; l >>= 8 {
+		b = append([]byte{byte(l)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
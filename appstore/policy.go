@@ -0,0 +1,137 @@
+package appstore
+
+import (
+	"strings"
+	"time"
+)
+
+// OwnershipTypeFamilyShared is `in_app_ownership_type` for the purchase shared by the family member
+const OwnershipTypeFamilyShared = "FAMILY_SHARED"
+
+// PolicyRule is the name of the rule in Policy
+type PolicyRule string
+
+const (
+	PolicyRuleBundleID           PolicyRule = "bundle_id"
+	PolicyRuleProductID          PolicyRule = "product_id"
+	PolicyRuleApplicationVersion PolicyRule = "application_version"
+	PolicyRuleEnvironment        PolicyRule = "environment"
+	PolicyRuleReceiptAge         PolicyRule = "receipt_age"
+	PolicyRuleRevoked            PolicyRule = "revoked"
+	PolicyRuleFamilyShared       PolicyRule = "family_shared"
+)
+
+// Policy is a set of rules which the valid receipt should satisfy.
+// Empty fields are not checked.
+type Policy struct {
+	BundleIDs             []string
+	ProductIDs            []string
+	MinApplicationVersion string
+	Environments          []string
+	// MaxReceiptAge is the maximum duration from the creation of the receipt
+	MaxReceiptAge      time.Duration
+	RejectRevoked      bool
+	RejectFamilyShared bool
+}
+
+// Violation is the violated rule of Policy.
+// TransactionID is set for the rules of in-app purchases.
+type Violation struct {
+	Rule          PolicyRule
+	Message       string
+	TransactionID int64
+}
+
+// PolicyError is returned when the receipt violates Policy
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "The receipt violates the policy: " + strings.Join(messages, ", ")
+}
+
+// HasRule checks the rule is violated or not
+func (e *PolicyError) HasRule(rule PolicyRule) bool {
+	for _, v := range e.Violations {
+		if v.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the list of violated rules for the receipt
+func (p *Policy) Check(r *Receipt) []Violation {
+	return p.CheckAt(r, time.Now())
+}
+
+// CheckAt returns the list of violated rules for the receipt at the given time
+func (p *Policy) CheckAt(r *Receipt, now time.Time) []Violation {
+	var violations []Violation
+	add := func(rule PolicyRule, txID int64, message string) {
+		violations = append(violations, Violation{
+			Rule:          rule,
+			Message:       message,
+			TransactionID: txID,
+		})
+	}
+
+	if len(p.BundleIDs) != 0 && !containsString(p.BundleIDs, r.BundleID) {
+		add(PolicyRuleBundleID, 0, "bundle id is not allowed: "+r.BundleID)
+	}
+	if p.MinApplicationVersion != "" && compareVersion(r.ApplicationVersion, p.MinApplicationVersion) < 0 {
+		add(PolicyRuleApplicationVersion, 0, "application version is older than "+p.MinApplicationVersion+": "+r.ApplicationVersion)
+	}
+	if len(p.Environments) != 0 && !containsString(p.Environments, r.Environment) {
+		add(PolicyRuleEnvironment, 0, "environment is not allowed: "+r.Environment)
+	}
+	if p.MaxReceiptAge > 0 && !r.ReceiptCreationDate.IsZero() && now.Sub(r.ReceiptCreationDate) > p.MaxReceiptAge {
+		add(PolicyRuleReceiptAge, 0, "receipt is older than "+p.MaxReceiptAge.String())
+	}
+
+	checked := make(map[int64]bool)
+	checkInApps := func(rc ReceiptInApps) {
+		for _, v := range rc {
+			if checked[v.TransactionID] {
+				continue
+			}
+			checked[v.TransactionID] = true
+
+			if len(p.ProductIDs) != 0 && !containsString(p.ProductIDs, v.ProductID) {
+				add(PolicyRuleProductID, v.TransactionID, "product id is not allowed: "+v.ProductID)
+			}
+			if p.RejectRevoked && !v.CancellationDate.IsZero() {
+				add(PolicyRuleRevoked, v.TransactionID, "purchase is revoked: "+v.ProductID)
+			}
+			if p.RejectFamilyShared && v.InAppOwnershipType == OwnershipTypeFamilyShared {
+				add(PolicyRuleFamilyShared, v.TransactionID, "purchase is family shared: "+v.ProductID)
+			}
+		}
+	}
+	checkInApps(r.InApps)
+	checkInApps(r.LatestReceiptInfo)
+	return violations
+}
+
+// Validate returns PolicyError when the receipt violates the policy
+func (p *Policy) Validate(r *Receipt) error {
+	violations := p.Check(r)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &PolicyError{Violations: violations}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPolicyReceipt() *Receipt {
+	now := time.Now()
+	return &Receipt{
+		Environment:         "Production",
+		BundleID:            "com.example.app",
+		ApplicationVersion:  "1.2.0",
+		ReceiptCreationDate: now.Add(-time.Hour),
+		InApps: ReceiptInApps{
+			{TransactionID: 1, ProductID: "com.example.product.item"},
+			{TransactionID: 2, ProductID: "com.example.product.sub", InAppOwnershipType: OwnershipTypeFamilyShared},
+		},
+		LatestReceiptInfo: ReceiptInApps{
+			{TransactionID: 2, ProductID: "com.example.product.sub", InAppOwnershipType: OwnershipTypeFamilyShared},
+			{TransactionID: 3, ProductID: "com.example.product.old", CancellationDate: now},
+		},
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testPolicyReceipt()
+	assert.Len((&Policy{}).Check(r), 0)
+
+	p := &Policy{
+		BundleIDs:             []string{"com.example.app"},
+		ProductIDs:            []string{"com.example.product.item", "com.example.product.sub", "com.example.product.old"},
+		MinApplicationVersion: "1.2",
+		Environments:          []string{"Production"},
+		MaxReceiptAge:         24 * time.Hour,
+	}
+	assert.Len(p.Check(r), 0)
+	assert.NoError(p.Validate(r))
+
+	p = &Policy{
+		BundleIDs:             []string{"com.example.other"},
+		ProductIDs:            []string{"com.example.product.item"},
+		MinApplicationVersion: "1.10",
+		Environments:          []string{"Sandbox"},
+		MaxReceiptAge:         time.Minute,
+		RejectRevoked:         true,
+		RejectFamilyShared:    true,
+	}
+	violations := p.Check(r)
+	assert.Equal([]Violation{
+		{Rule: PolicyRuleBundleID, Message: "bundle id is not allowed: com.example.app"},
+		{Rule: PolicyRuleApplicationVersion, Message: "application version is older than 1.10: 1.2.0"},
+		{Rule: PolicyRuleEnvironment, Message: "environment is not allowed: Production"},
+		{Rule: PolicyRuleReceiptAge, Message: "receipt is older than 1m0s"},
+		{Rule: PolicyRuleProductID, TransactionID: 2, Message: "product id is not allowed: com.example.product.sub"},
+		{Rule: PolicyRuleFamilyShared, TransactionID: 2, Message: "purchase is family shared: com.example.product.sub"},
+		{Rule: PolicyRuleProductID, TransactionID: 3, Message: "product id is not allowed: com.example.product.old"},
+		{Rule: PolicyRuleRevoked, TransactionID: 3, Message: "purchase is revoked: com.example.product.old"},
+	}, violations)
+
+	// receipt age at the time
+	p = &Policy{MaxReceiptAge: 2 * time.Hour}
+	assert.Len(p.CheckAt(r, time.Now()), 0)
+	assert.Len(p.CheckAt(r, time.Now().Add(2*time.Hour)), 1)
+}
+
+func TestPolicyError(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Policy{BundleIDs: []string{"com.example.other"}, RejectRevoked: true}
+	err := p.Validate(testPolicyReceipt())
+	policyErr, ok := err.(*PolicyError)
+	assert.True(ok)
+	assert.True(policyErr.HasRule(PolicyRuleBundleID))
+	assert.True(policyErr.HasRule(PolicyRuleRevoked))
+	assert.False(policyErr.HasRule(PolicyRuleFamilyShared))
+	assert.Equal("The receipt violates the policy: bundle id is not allowed: com.example.app, purchase is revoked: com.example.product.old", err.Error())
+}
+
+func TestVerifyWithPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	server, client := testTools(200, testReceiptString)
+	defer server.Close()
+
+	client.Policy = &Policy{BundleIDs: []string{"com.example.app"}}
+	_, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+	assert.NoError(err)
+
+	client.Policy = &Policy{BundleIDs: []string{"com.example.other"}}
+	resp, err := client.Verify(IAPRequest{ReceiptData: "dummy data"})
+	assert.IsType(&PolicyError{}, err)
+	assert.NotNil(resp)
+
+	// policy is not applied to invalid receipt
+	server, client = testTools(200, `{"status": 21002}`)
+	defer server.Close()
+	client.Policy = &Policy{BundleIDs: []string{"com.example.other"}}
+	resp, err = client.Verify(IAPRequest{ReceiptData: "dummy data"})
+	assert.NoError(err)
+	assert.Equal(21002, resp.Status)
+}
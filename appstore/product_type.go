@@ -0,0 +1,107 @@
+package appstore
+
+// ProductType is the type of in-app purchase product
+type ProductType int
+
+const (
+	ProductTypeUnknown ProductType = iota
+	ProductTypeConsumable
+	ProductTypeNonConsumable
+	ProductTypeAutoRenewable
+	ProductTypeNonRenewing
+)
+
+func (t ProductType) String() string {
+	switch t {
+	case ProductTypeConsumable:
+		return "consumable"
+	case ProductTypeNonConsumable:
+		return "non-consumable"
+	case ProductTypeAutoRenewable:
+		return "auto-renewable"
+	case ProductTypeNonRenewing:
+		return "non-renewing"
+	}
+	return "unknown"
+}
+
+// ProductCatalog is the types of the products by product id, as registered in App Store Connect
+type ProductCatalog map[string]ProductType
+
+// Type returns the type of the product, or ProductTypeUnknown when it's not in the catalog
+func (c ProductCatalog) Type(productID string) ProductType {
+	return c[productID]
+}
+
+// ProductType returns the type of the purchased product in the catalog.
+// When the product is not in the catalog, the purchase with `expires_date` is classified as auto-renewable,
+// because only auto-renewable subscriptions have it in the receipt, and others are ProductTypeUnknown.
+func (r *ReceiptInApp) ProductType(catalog ProductCatalog) ProductType {
+	if t := catalog.Type(r.ProductID); t != ProductTypeUnknown {
+		return t
+	}
+	if !r.ExpiresDate.IsZero() {
+		return ProductTypeAutoRenewable
+	}
+	return ProductTypeUnknown
+}
+
+// IsRefunded checks the purchase is cancelled by Apple customer support or not
+func (r *ReceiptInApp) IsRefunded() bool {
+	return !r.CancellationDate.IsZero()
+}
+
+// ByProductType returns the purchases of the product type in the catalog
+func (r ReceiptInApps) ByProductType(catalog ProductCatalog, t ProductType) ReceiptInApps {
+	var matched ReceiptInApps
+	for _, v := range r {
+		if v.ProductType(catalog) == t {
+			matched = append(matched, v)
+		}
+	}
+	return matched
+}
+
+// HasProductType checks the purchases have the product type in the catalog or not
+func (r ReceiptInApps) HasProductType(catalog ProductCatalog, t ProductType) bool {
+	for _, v := range r {
+		if v.ProductType(catalog) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAutoRenewable checks this receipt has auto-renewable subscriptions in the catalog or not.
+// Unlike IsAutoRenewable, the receipt with consumables and subscriptions returns true.
+func (r *Receipt) HasAutoRenewable(catalog ProductCatalog) bool {
+	return r.InApps.HasProductType(catalog, ProductTypeAutoRenewable) ||
+		r.LatestReceiptInfo.HasProductType(catalog, ProductTypeAutoRenewable)
+}
+
+// OwnedNonConsumables returns the first purchase of each non-consumable product in the catalog except refunded ones
+func (r *Receipt) OwnedNonConsumables(catalog ProductCatalog) ReceiptInApps {
+	seen := make(map[string]bool)
+	var owned ReceiptInApps
+	for _, v := range r.InApps.ByProductType(catalog, ProductTypeNonConsumable) {
+		if v.IsRefunded() || seen[v.ProductID] {
+			continue
+		}
+		seen[v.ProductID] = true
+		owned = append(owned, v)
+	}
+	return owned
+}
+
+// UnfinishedConsumables returns the purchases of consumable products in the catalog except refunded ones.
+// The App Store keeps consumables in the receipt until the app finishes the transaction,
+// so these purchases may be not delivered yet.
+func (r *Receipt) UnfinishedConsumables(catalog ProductCatalog) ReceiptInApps {
+	var list ReceiptInApps
+	for _, v := range r.InApps.ByProductType(catalog, ProductTypeConsumable) {
+		if !v.IsRefunded() {
+			list = append(list, v)
+		}
+	}
+	return list
+}
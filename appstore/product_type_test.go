@@ -0,0 +1,101 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCatalog = ProductCatalog{
+	"coin":    ProductTypeConsumable,
+	"premium": ProductTypeNonConsumable,
+	"theme":   ProductTypeNonConsumable,
+	"monthly": ProductTypeAutoRenewable,
+	"season":  ProductTypeNonRenewing,
+}
+
+func testProductTypeReceipt() *Receipt {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Receipt{
+		InApps: ReceiptInApps{
+			{TransactionID: 1, ProductID: "coin", PurchaseDate: now},
+			{TransactionID: 2, ProductID: "coin", PurchaseDate: now, CancellationDate: now},
+			{TransactionID: 3, ProductID: "premium", PurchaseDate: now},
+			{TransactionID: 4, ProductID: "premium", PurchaseDate: now},
+			{TransactionID: 5, ProductID: "theme", PurchaseDate: now, CancellationDate: now},
+			{TransactionID: 6, ProductID: "season", PurchaseDate: now},
+			{TransactionID: 7, ProductID: "monthly", PurchaseDate: now, ExpiresDate: now.AddDate(0, 1, 0)},
+			{TransactionID: 8, ProductID: "unknown", PurchaseDate: now},
+		},
+	}
+}
+
+func TestProductType(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testProductTypeReceipt()
+	tests := []struct {
+		catalog  ProductCatalog
+		expected []ProductType
+	}{
+		{testCatalog, []ProductType{
+			ProductTypeConsumable,
+			ProductTypeConsumable,
+			ProductTypeNonConsumable,
+			ProductTypeNonConsumable,
+			ProductTypeNonConsumable,
+			ProductTypeNonRenewing,
+			ProductTypeAutoRenewable,
+			ProductTypeUnknown,
+		}},
+		{nil, []ProductType{
+			ProductTypeUnknown,
+			ProductTypeUnknown,
+			ProductTypeUnknown,
+			ProductTypeUnknown,
+			ProductTypeUnknown,
+			ProductTypeUnknown,
+			ProductTypeAutoRenewable,
+			ProductTypeUnknown,
+		}},
+	}
+
+	for _, tt := range tests {
+		for i, v := range r.InApps {
+			assert.Equal(tt.expected[i], v.ProductType(tt.catalog))
+		}
+	}
+	assert.Equal("non-renewing", ProductTypeNonRenewing.String())
+	assert.Equal([]int64{6}, r.InApps.ByProductType(testCatalog, ProductTypeNonRenewing).TransactionIDs())
+}
+
+func TestHasAutoRenewable(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testProductTypeReceipt()
+	assert.False(r.IsAutoRenewable())
+	assert.True(r.HasAutoRenewable(testCatalog))
+	assert.True(r.HasAutoRenewable(nil))
+
+	r.InApps = r.InApps[:6]
+	assert.False(r.HasAutoRenewable(testCatalog))
+	r.LatestReceiptInfo = ReceiptInApps{{TransactionID: 9, ProductID: "monthly"}}
+	assert.True(r.HasAutoRenewable(testCatalog))
+}
+
+func TestOwnedNonConsumables(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testProductTypeReceipt()
+	assert.Equal([]int64{3}, r.OwnedNonConsumables(testCatalog).TransactionIDs())
+	assert.Empty(r.OwnedNonConsumables(nil))
+}
+
+func TestUnfinishedConsumables(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testProductTypeReceipt()
+	assert.Equal([]int64{1}, r.UnfinishedConsumables(testCatalog).TransactionIDs())
+	assert.Empty(r.UnfinishedConsumables(nil))
+}
@@ -0,0 +1,101 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promotionalOfferSeparator is the invisible separator (U+2063) between the fields of the payload
+const promotionalOfferSeparator = "\u2063"
+
+// ErrInvalidPromotionalOfferSignature is returned when the signature of the promotional offer cannot be verified
+var ErrInvalidPromotionalOfferSignature = errors.New("The signature of the promotional offer cannot be verified.")
+
+// ecdsaSignature is ECDSA signature in ASN.1 DER format
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// PromotionalOffer has the parameters of the promotional offer to sign
+// see: https://developer.apple.com/documentation/storekit/in-app_purchase/original_api_for_in-app_purchase/subscriptions_and_offers/generating_a_signature_for_promotional_offers
+type PromotionalOffer struct {
+	AppBundleID         string
+	KeyIdentifier       string
+	ProductIdentifier   string
+	OfferIdentifier     string
+	ApplicationUsername string
+}
+
+// PromotionalOfferSignature has the values to pass to SKPaymentDiscount in the app
+type PromotionalOfferSignature struct {
+	KeyIdentifier string
+	Nonce         string
+	Timestamp     int64
+	Signature     string
+}
+
+// payload builds the string to sign in the order and the separator which the App Store expects
+func (o PromotionalOffer) payload(nonce string, timestamp int64) string {
+	return strings.Join([]string{
+		o.AppBundleID,
+		o.KeyIdentifier,
+		o.ProductIdentifier,
+		o.OfferIdentifier,
+		o.ApplicationUsername,
+		strings.ToLower(nonce),
+		strconv.FormatInt(timestamp, 10),
+	}, promotionalOfferSeparator)
+}
+
+// SignPromotionalOffer signs the promotional offer with the subscription key (.p8) from App Store Connect.
+// Nonce is a new UUID and Timestamp is the current time in milliseconds.
+func SignPromotionalOffer(privateKey []byte, offer PromotionalOffer) (*PromotionalOfferSignature, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := newUUID()
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	hash := sha256.Sum256([]byte(offer.payload(nonce, timestamp)))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PromotionalOfferSignature{
+		KeyIdentifier: offer.KeyIdentifier,
+		Nonce:         nonce,
+		Timestamp:     timestamp,
+		Signature:     base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// VerifyPromotionalOffer verifies the signature of the promotional offer with the public key of the subscription key
+func VerifyPromotionalOffer(publicKey *ecdsa.PublicKey, offer PromotionalOffer, sig *PromotionalOfferSignature) error {
+	der, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return ErrInvalidPromotionalOfferSignature
+	}
+	var es ecdsaSignature
+	if _, err := asn1.Unmarshal(der, &es); err != nil {
+		return ErrInvalidPromotionalOfferSignature
+	}
+	hash := sha256.Sum256([]byte(offer.payload(sig.Nonce, sig.Timestamp)))
+	if !ecdsa.Verify(publicKey, hash[:], es.R, es.S) {
+		return ErrInvalidPromotionalOfferSignature
+	}
+	return nil
+}
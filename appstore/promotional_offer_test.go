@@ -0,0 +1,68 @@
+package appstore
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotionalOfferPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	offer := PromotionalOffer{
+		AppBundleID:         "com.example.app",
+		KeyIdentifier:       "KEYID",
+		ProductIdentifier:   "com.example.product.sub",
+		OfferIdentifier:     "winback",
+		ApplicationUsername: "user-hash",
+	}
+	expected := "com.example.app\u2063KEYID\u2063com.example.product.sub\u2063winback\u2063user-hash\u2063" +
+		"a1b2c3d4-0000-4000-8000-000000000000\u20631582000000000"
+	assert.Equal(expected, offer.payload("A1B2C3D4-0000-4000-8000-000000000000", 1582000000000))
+}
+
+func TestSignPromotionalOffer(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPEM, key := testPrivateKeyPEM(t)
+	offer := PromotionalOffer{
+		AppBundleID:         "com.example.app",
+		KeyIdentifier:       "KEYID",
+		ProductIdentifier:   "com.example.product.sub",
+		OfferIdentifier:     "winback",
+		ApplicationUsername: "user-hash",
+	}
+
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	sig, err := SignPromotionalOffer(keyPEM, offer)
+	assert.NoError(err)
+	assert.Equal("KEYID", sig.KeyIdentifier)
+	assert.Regexp(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), sig.Nonce)
+	assert.True(sig.Timestamp >= before)
+	assert.NoError(VerifyPromotionalOffer(&key.PublicKey, offer, sig))
+
+	// nonce is unique for each signature
+	sig2, err := SignPromotionalOffer(keyPEM, offer)
+	assert.NoError(err)
+	assert.NotEqual(sig.Nonce, sig2.Nonce)
+
+	// other offer
+	other := offer
+	other.OfferIdentifier = "other"
+	assert.Equal(ErrInvalidPromotionalOfferSignature, VerifyPromotionalOffer(&key.PublicKey, other, sig))
+
+	// other key
+	_, otherKey := testPrivateKeyPEM(t)
+	assert.Equal(ErrInvalidPromotionalOfferSignature, VerifyPromotionalOffer(&otherKey.PublicKey, offer, sig))
+
+	// broken signature
+	broken := *sig
+	broken.Signature = strings.Repeat("A", 12)
+	assert.Equal(ErrInvalidPromotionalOfferSignature, VerifyPromotionalOffer(&key.PublicKey, offer, &broken))
+
+	_, err = SignPromotionalOffer([]byte("invalid"), offer)
+	assert.Error(err)
+}
@@ -10,6 +10,7 @@ type Receipt struct {
 	rawReceipt      string
 	Status          int
 	Environment     string
+	IsRetryable     bool
 
 	ReceiptType                string
 	AdamID                     int64
@@ -20,12 +21,15 @@ type Receipt struct {
 	OriginalApplicationVersion string
 	RequestDate                time.Time
 	OriginalPurchaseDate       time.Time
+	ReceiptCreationDate        time.Time
 	InApps                     ReceiptInApps
 
 	LatestReceiptInfo ReceiptInApps
 	LatestReceipt     string
 
 	PendingRenewalInfo ReceiptPendingRenewalInfos
+
+	index *receiptIndex
 }
 
 func (r *Receipt) String() string {
@@ -52,6 +56,11 @@ func (r *Receipt) GetEnvironment() string {
 	return r.Environment
 }
 
+// GetOriginalApplicationVersion returns `original_application_version`
+func (r *Receipt) GetOriginalApplicationVersion() string {
+	return r.OriginalApplicationVersion
+}
+
 // LatestReceiptString returns raw receipt of `latest_receipt`
 func (r *Receipt) LatestReceiptString() string {
 	return r.LatestReceipt
@@ -63,7 +72,8 @@ func (r *Receipt) IsValidReceipt() bool {
 	return r.Status == 0
 }
 
-// IsAutoRenewable checks this receipt is auto-renewable subscription or not
+// IsAutoRenewable checks this receipt is auto-renewable subscription or not.
+// All of `in_app` must have `expires_date`, so use HasAutoRenewable for the receipt with other products.
 func (r *Receipt) IsAutoRenewable() bool {
 	return r.InApps.IsAutoRenewable()
 }
@@ -72,6 +82,17 @@ func (r *Receipt) HasError() error {
 	return HandleError(r.Status)
 }
 
+// HasServerError checks this receipt status is 21005 or 21100-21199,
+// which means the temporary error in App Store
+func (r *Receipt) HasServerError() bool {
+	return r.Status == 21005 || (r.Status >= 21100 && r.Status <= 21199)
+}
+
+// ShouldRetry checks the verification of this receipt should be retried later
+func (r *Receipt) ShouldRetry() bool {
+	return r.IsRetryable || r.HasServerError()
+}
+
 // HasExpired checks this receipt is expired or not (only for iOS6 style)
 func (r *Receipt) HasExpired() bool {
 	return r.Status == 21006
@@ -91,93 +112,80 @@ func (r *Receipt) ShouldSendToProductionEnvironment() bool {
 
 // GetTransactionIDs returns all of transaction_id from `in_app`
 func (r *Receipt) GetTransactionIDs() []int64 {
-	return r.InApps.TransactionIDs()
+	return r.inAppQuery().TransactionIDs()
 }
 
 // GetTransactionIDsByProduct returns all of transaction_id from `in_app` filtered by `product_id`
 func (r *Receipt) GetTransactionIDsByProduct(product string) []int64 {
-	checked := make(map[int64]bool)
 	var matched []int64
-	latest := r.LatestReceiptInfo.LastExpiresByProductIDForLatest(product)
+	latest := r.latestReceiptInfoQuery().LastExpiresByProductIDForLatest(product)
 	if latest != nil {
-		txID := latest.TransactionID
-		checked[txID] = true
-		matched = append(matched, txID)
+		matched = append(matched, latest.TransactionID)
 	}
 
-	for _, v := range r.InApps {
-		txID := v.TransactionID
-		if checked[txID] {
-			continue
-		}
-		checked[txID] = true
-		if v.ProductID != product {
+	for _, v := range r.inAppQuery().firstByProduct(product) {
+		if latest != nil && v.TransactionID == latest.TransactionID {
 			continue
 		}
-		matched = append(matched, txID)
+		matched = append(matched, v.TransactionID)
 	}
 	return matched
 }
 
 // GetTransactionIDsWithoutExpired returns all of transaction_id except expired
 func (r *Receipt) GetTransactionIDsWithoutExpired() []int64 {
-	checked := make(map[int64]bool)
-	now := time.Now()
-	var matched []int64
-	matchedIDs := func(rc ReceiptInApps) {
-		for _, v := range rc {
-			if checked[v.TransactionID] {
-				continue
-			}
-			checked[v.TransactionID] = true
-
-			switch {
-			case !v.ExpiresDate.IsZero() && v.ExpiresDate.Before(now):
-				continue
-			}
-			matched = append(matched, v.TransactionID)
-		}
-	}
-	matchedIDs(r.InApps)
-	matchedIDs(r.LatestReceiptInfo)
-	return matched
+	return r.GetTransactionIDsWithoutExpiredAt(time.Now())
+}
+
+// GetTransactionIDsWithoutExpiredAt returns all of transaction_id except expired at the given time
+func (r *Receipt) GetTransactionIDsWithoutExpiredAt(t time.Time) []int64 {
+	return r.transactionIDsWithoutExpired(t, func(*ReceiptInApp) bool {
+		return true
+	})
 }
 
 // GetTransactionIDsByProductWithoutExpired returns all of transaction_id filtered by `product_id` except expired
 func (r *Receipt) GetTransactionIDsByProductWithoutExpired(product string) []int64 {
-	checked := make(map[int64]bool)
-	now := time.Now()
+	return r.GetTransactionIDsByProductWithoutExpiredAt(product, time.Now())
+}
+
+// GetTransactionIDsByProductWithoutExpiredAt returns all of transaction_id filtered by `product_id` except expired at the given time
+func (r *Receipt) GetTransactionIDsByProductWithoutExpiredAt(product string, t time.Time) []int64 {
+	return r.transactionIDsWithoutExpired(t, func(v *ReceiptInApp) bool {
+		return v.ProductID == product
+	})
+}
+
+// transactionIDsWithoutExpired returns transaction_id from `in_app` and `latest_receipt_info` except expired at the time.
+// The data in `latest_receipt_info` is ignored when `in_app` has the same transaction_id.
+func (r *Receipt) transactionIDsWithoutExpired(now time.Time, match func(*ReceiptInApp) bool) []int64 {
+	inApps := r.inAppQuery()
 	var matched []int64
-	matchedIDs := func(rc ReceiptInApps) {
-		for _, v := range rc {
-			if checked[v.TransactionID] {
-				continue
-			}
-			checked[v.TransactionID] = true
-
-			switch {
-			case v.ProductID != product:
-				continue
-			case !v.ExpiresDate.IsZero() && v.ExpiresDate.Before(now):
-				continue
-			}
+	for _, v := range inApps.withoutExpired(now) {
+		if match(v) {
 			matched = append(matched, v.TransactionID)
 		}
 	}
-	matchedIDs(r.InApps)
-	matchedIDs(r.LatestReceiptInfo)
+	for _, v := range r.latestReceiptInfoQuery().withoutExpired(now) {
+		switch {
+		case !match(v),
+			inApps.ByTransactionID(v.TransactionID) != nil:
+			continue
+		}
+		matched = append(matched, v.TransactionID)
+	}
 	return matched
 }
 
 // GetByTransactionID returns receipt data by `transaction_id`
 func (r *Receipt) GetByTransactionID(id int64) *ReceiptInApp {
-	return r.InApps.ByTransactionID(id)
+	return r.inAppQuery().ByTransactionID(id)
 }
 
 // GetLastExpiresByProductID returns latest expires receipt data by `product_id`
 func (r *Receipt) GetLastExpiresByProductID(productID string) *ReceiptInApp {
-	inAppLatest := r.LatestReceiptInfo.LastExpiresByProductIDForLatest(productID)
-	inApp := r.InApps.LastExpiresByProductID(productID)
+	inAppLatest := r.latestReceiptInfoQuery().LastExpiresByProductIDForLatest(productID)
+	inApp := r.inAppQuery().LastExpiresByProductID(productID)
 	switch {
 	case inApp == nil:
 		return inAppLatest
@@ -191,8 +199,8 @@ func (r *Receipt) GetLastExpiresByProductID(productID string) *ReceiptInApp {
 
 // GetLastExpiresByTransactionIDs returns latest expires receipt data from `transaction_id` list
 func (r *Receipt) GetLastExpiresByTransactionIDs(ids []int64) *ReceiptInApp {
-	inAppLatest := r.LatestReceiptInfo.LastExpiresByTransactionIDsForLatest(ids)
-	inApp := r.InApps.LastExpiresByTransactionIDs(ids)
+	inAppLatest := r.latestReceiptInfoQuery().LastExpiresByTransactionIDsForLatest(ids)
+	inApp := r.inAppQuery().LastExpiresByTransactionIDs(ids)
 	switch {
 	case inApp == nil:
 		return inAppLatest
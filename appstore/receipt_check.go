@@ -0,0 +1,63 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// MaxReceiptDataLength is the maximum length of base64 encoded receipt data accepted by CheckReceiptData
+const MaxReceiptDataLength = 10 << 20
+
+// ReceiptDataReason is the reason of ReceiptDataError
+type ReceiptDataReason string
+
+const (
+	ReceiptDataEmpty         ReceiptDataReason = "empty"
+	ReceiptDataTooLarge      ReceiptDataReason = "too_large"
+	ReceiptDataInvalidBase64 ReceiptDataReason = "invalid_base64"
+	ReceiptDataUnknownFormat ReceiptDataReason = "unknown_format"
+)
+
+// ReceiptDataError is returned when the receipt data is malformed before sending it to the App Store
+type ReceiptDataError struct {
+	Reason  ReceiptDataReason
+	Message string
+}
+
+func (e *ReceiptDataError) Error() string {
+	return "The data in the receipt-data property was malformed: " + e.Message
+}
+
+// CheckReceiptData checks the shape of the receipt data without sending it to the App Store.
+// The receipt data should be base64 encoded PKCS#7 signed data (app receipt)
+// or old-style plist having purchase-info and signature (iOS 6 style transaction receipt).
+// Line breaks in base64 are allowed. Note that the signature is not verified.
+func CheckReceiptData(receiptData string) error {
+	switch {
+	case receiptData == "":
+		return &ReceiptDataError{Reason: ReceiptDataEmpty, Message: "the receipt data is empty"}
+	case len(receiptData) > MaxReceiptDataLength:
+		return &ReceiptDataError{
+			Reason:  ReceiptDataTooLarge,
+			Message: "the length " + strconv.Itoa(len(receiptData)) + " exceeds " + strconv.Itoa(MaxReceiptDataLength),
+		}
+	}
+
+	s := strings.NewReplacer("\r", "", "\n", "").Replace(receiptData)
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return &ReceiptDataError{Reason: ReceiptDataInvalidBase64, Message: err.Error()}
+	}
+
+	if _, err := parsePKCS7Content(raw); err == nil {
+		return nil
+	}
+	if dict, err := parsePlistDict(string(raw)); err == nil && dict["purchase-info"] != "" && dict["signature"] != "" {
+		return nil
+	}
+	return &ReceiptDataError{
+		Reason:  ReceiptDataUnknownFormat,
+		Message: "neither PKCS#7 signed data nor plist having purchase-info and signature",
+	}
+}
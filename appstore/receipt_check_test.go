@@ -0,0 +1,66 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/evalphobia/go-iap/observer"
+)
+
+func TestCheckReceiptData(t *testing.T) {
+	assert := assert.New(t)
+
+	pkcs7 := base64.StdEncoding.EncodeToString(testLocalReceiptData(t, testGUID, false))
+	tests := []struct {
+		receiptData string
+		reason      ReceiptDataReason
+	}{
+		{pkcs7, ""},
+		{base64.StdEncoding.EncodeToString(testLocalReceiptData(t, testGUID, true)), ""},
+		{pkcs7[:64] + "\r\n" + pkcs7[64:], ""},
+		{testTransactionReceiptIOS6(testPurchaseInfoIOS6), ""},
+		{"", ReceiptDataEmpty},
+		{strings.Repeat("A", MaxReceiptDataLength+4), ReceiptDataTooLarge},
+		{"not base64!", ReceiptDataInvalidBase64},
+		{strings.Replace(pkcs7, "+", " ", -1), ReceiptDataInvalidBase64},
+		{pkcs7[:len(pkcs7)/4*2+1], ReceiptDataInvalidBase64},
+		{pkcs7[:len(pkcs7)/4*2], ReceiptDataUnknownFormat},
+		{base64.StdEncoding.EncodeToString(testLocalReceiptData(t, testGUID, false)[:100]), ReceiptDataUnknownFormat},
+		{base64.StdEncoding.EncodeToString([]byte(`{"signature" = "abc";}`)), ReceiptDataUnknownFormat},
+		{base64.StdEncoding.EncodeToString([]byte("hello")), ReceiptDataUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		err := CheckReceiptData(tt.receiptData)
+		if tt.reason == "" {
+			assert.NoError(err)
+			continue
+		}
+		if assert.IsType(&ReceiptDataError{}, err) {
+			assert.Equal(tt.reason, err.(*ReceiptDataError).Reason)
+		}
+	}
+}
+
+func TestVerifyPrecheckReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	obs := observer.NewCountingObserver()
+	client := NewWithConfig(Config{PrecheckReceipt: true, Observer: obs})
+	client.URL = "http://127.0.0.1:0/verifyReceipt"
+	receipt, err := client.Verify(IAPRequest{ReceiptData: "not base64!"})
+	assert.Nil(receipt)
+	assert.IsType(&ReceiptDataError{}, err)
+	assert.Contains(err.Error(), "malformed")
+
+	// the rejection is observed without any request
+	events := obs.Events()
+	if assert.Len(events, 1) {
+		assert.Equal(err, events[0].Err)
+		assert.Equal(client.URL, events[0].Endpoint)
+		assert.Equal(0, events[0].HTTPStatus)
+	}
+}
@@ -14,12 +14,19 @@ type ReceiptInApp struct {
 	AppItemID                 int64
 	VersionExternalIdentifier int64
 	WebOrderLineItemID        int64
+	InAppOwnershipType        string
 	PurchaseDate              time.Time
 	OriginalPurchaseDate      time.Time
 	ExpiresDate               time.Time
 	CancellationDate          time.Time
 }
 
+// IsExpiredAt checks the subscription has been already expired at the given time.
+// The purchase without `expires_date` is never expired.
+func (r *ReceiptInApp) IsExpiredAt(t time.Time) bool {
+	return !r.ExpiresDate.IsZero() && r.ExpiresDate.Before(t)
+}
+
 type ReceiptInApps []*ReceiptInApp
 
 func (r ReceiptInApps) IsAutoRenewable() bool {
@@ -40,6 +47,17 @@ func (r ReceiptInApps) ByTransactionID(id int64) *ReceiptInApp {
 	return nil
 }
 
+func (r ReceiptInApps) ByOriginalTransactionID(id int64) ReceiptInApps {
+	var matched ReceiptInApps
+	for _, v := range r {
+		if v.OriginalTransactionID != id {
+			continue
+		}
+		matched = append(matched, v)
+	}
+	return matched
+}
+
 func (r ReceiptInApps) ByProduct(productID string) ReceiptInApps {
 	var matched ReceiptInApps
 	for _, v := range r {
@@ -130,3 +148,36 @@ func (r ReceiptInApps) LastExpiresByTransactionIDsForLatest(ids []int64) *Receip
 	}
 	return nil
 }
+
+func (r ReceiptInApps) firstByProduct(productID string) ReceiptInApps {
+	checked := make(map[int64]bool)
+	var matched ReceiptInApps
+	for _, v := range r {
+		if checked[v.TransactionID] {
+			continue
+		}
+		checked[v.TransactionID] = true
+		if v.ProductID != productID {
+			continue
+		}
+		matched = append(matched, v)
+	}
+	return matched
+}
+
+func (r ReceiptInApps) withoutExpired(now time.Time) ReceiptInApps {
+	checked := make(map[int64]bool)
+	var matched ReceiptInApps
+	for _, v := range r {
+		if checked[v.TransactionID] {
+			continue
+		}
+		checked[v.TransactionID] = true
+
+		if v.IsExpiredAt(now) {
+			continue
+		}
+		matched = append(matched, v)
+	}
+	return matched
+}
@@ -0,0 +1,232 @@
+package appstore
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// receiptInAppQuery is implemented by ReceiptInApps (linear scan) and *ReceiptInAppIndex
+type receiptInAppQuery interface {
+	ByTransactionID(id int64) *ReceiptInApp
+	TransactionIDs() []int64
+	LastExpiresByProductID(productID string) *ReceiptInApp
+	LastExpiresByProductIDForLatest(productID string) *ReceiptInApp
+	LastExpiresByTransactionIDs(ids []int64) *ReceiptInApp
+	LastExpiresByTransactionIDsForLatest(ids []int64) *ReceiptInApp
+
+	// firstByProduct returns the first data of each transaction_id in order, filtered by `product_id`
+	firstByProduct(productID string) ReceiptInApps
+	// withoutExpired returns the first data of each transaction_id in order, except expired at the time
+	withoutExpired(now time.Time) ReceiptInApps
+}
+
+// ReceiptInAppIndex is the index of ReceiptInApps for large subscription histories.
+// Each query returns the same result as the method of ReceiptInApps with the same name.
+// ReceiptInApps must not be modified after the index is created.
+type ReceiptInAppIndex struct {
+	inApps                  ReceiptInApps
+	byTransactionID         map[int64][]int
+	byOriginalTransactionID map[int64][]int
+	byProduct               map[string][]int
+	lastExpiresByProduct    map[string]int
+	// positions sorted by expires_date
+	byExpires []int
+}
+
+// NewReceiptInAppIndex creates the index of ReceiptInApps
+func NewReceiptInAppIndex(r ReceiptInApps) *ReceiptInAppIndex {
+	idx := &ReceiptInAppIndex{
+		inApps:                  r,
+		byTransactionID:         make(map[int64][]int, len(r)),
+		byOriginalTransactionID: make(map[int64][]int),
+		byProduct:               make(map[string][]int),
+		lastExpiresByProduct:    make(map[string]int),
+		byExpires:               make([]int, len(r)),
+	}
+	for i, v := range r {
+		idx.byTransactionID[v.TransactionID] = append(idx.byTransactionID[v.TransactionID], i)
+		idx.byOriginalTransactionID[v.OriginalTransactionID] = append(idx.byOriginalTransactionID[v.OriginalTransactionID], i)
+		idx.byProduct[v.ProductID] = append(idx.byProduct[v.ProductID], i)
+		if latest, ok := idx.lastExpiresByProduct[v.ProductID]; !ok || !r[latest].ExpiresDate.After(v.ExpiresDate) {
+			idx.lastExpiresByProduct[v.ProductID] = i
+		}
+		idx.byExpires[i] = i
+	}
+	sort.SliceStable(idx.byExpires, func(i, j int) bool {
+		return r[idx.byExpires[i]].ExpiresDate.Before(r[idx.byExpires[j]].ExpiresDate)
+	})
+	return idx
+}
+
+func (idx *ReceiptInAppIndex) ByTransactionID(id int64) *ReceiptInApp {
+	if list, ok := idx.byTransactionID[id]; ok {
+		return idx.inApps[list[0]]
+	}
+	return nil
+}
+
+func (idx *ReceiptInAppIndex) ByOriginalTransactionID(id int64) ReceiptInApps {
+	return idx.list(idx.byOriginalTransactionID[id])
+}
+
+func (idx *ReceiptInAppIndex) ByProduct(productID string) ReceiptInApps {
+	return idx.list(idx.byProduct[productID])
+}
+
+func (idx *ReceiptInAppIndex) TransactionIDs() []int64 {
+	return idx.inApps.TransactionIDs()
+}
+
+func (idx *ReceiptInAppIndex) TransactionIDsByProduct(productID string) []int64 {
+	return idx.ByProduct(productID).TransactionIDs()
+}
+
+// for auto-renewable
+func (idx *ReceiptInAppIndex) LastExpiresByProductID(productID string) *ReceiptInApp {
+	if i, ok := idx.lastExpiresByProduct[productID]; ok {
+		return idx.inApps[i]
+	}
+	return nil
+}
+
+// for LatestReceiptInfo
+func (idx *ReceiptInAppIndex) LastExpiresByProductIDForLatest(productID string) *ReceiptInApp {
+	list := idx.byProduct[productID]
+	if len(list) == 0 {
+		return nil
+	}
+	return idx.inApps[list[len(list)-1]]
+}
+
+func (idx *ReceiptInAppIndex) LastExpiresByTransactionIDs(ids []int64) *ReceiptInApp {
+	latest := -1
+	for _, i := range idx.positions(ids) {
+		switch {
+		case latest < 0,
+			!idx.inApps[latest].ExpiresDate.After(idx.inApps[i].ExpiresDate):
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return nil
+	}
+	return idx.inApps[latest]
+}
+
+func (idx *ReceiptInAppIndex) LastExpiresByTransactionIDsForLatest(ids []int64) *ReceiptInApp {
+	list := idx.positions(ids)
+	if len(list) == 0 {
+		return nil
+	}
+	return idx.inApps[list[len(list)-1]]
+}
+
+func (idx *ReceiptInAppIndex) firstByProduct(productID string) ReceiptInApps {
+	var matched ReceiptInApps
+	for _, i := range idx.byProduct[productID] {
+		if idx.isFirst(i) {
+			matched = append(matched, idx.inApps[i])
+		}
+	}
+	return matched
+}
+
+func (idx *ReceiptInAppIndex) withoutExpired(now time.Time) ReceiptInApps {
+	// zero expires_date (not auto-renewable) is sorted at first
+	zero := sort.Search(len(idx.byExpires), func(i int) bool {
+		return !idx.inApps[idx.byExpires[i]].ExpiresDate.IsZero()
+	})
+	valid := sort.Search(len(idx.byExpires), func(i int) bool {
+		return !idx.inApps[idx.byExpires[i]].ExpiresDate.Before(now)
+	})
+	if valid < zero {
+		valid = zero
+	}
+
+	list := make([]int, 0, zero+len(idx.byExpires)-valid)
+	list = append(list, idx.byExpires[:zero]...)
+	list = append(list, idx.byExpires[valid:]...)
+	sort.Ints(list)
+
+	var matched ReceiptInApps
+	for _, i := range list {
+		if idx.isFirst(i) {
+			matched = append(matched, idx.inApps[i])
+		}
+	}
+	return matched
+}
+
+// isFirst checks the position is the first data of the transaction_id or not
+func (idx *ReceiptInAppIndex) isFirst(i int) bool {
+	return idx.byTransactionID[idx.inApps[i].TransactionID][0] == i
+}
+
+// positions returns the sorted positions of the transaction_id list
+func (idx *ReceiptInAppIndex) positions(ids []int64) []int {
+	checked := make(map[int64]bool, len(ids))
+	var list []int
+	for _, id := range ids {
+		if checked[id] {
+			continue
+		}
+		checked[id] = true
+		list = append(list, idx.byTransactionID[id]...)
+	}
+	sort.Ints(list)
+	return list
+}
+
+func (idx *ReceiptInAppIndex) list(positions []int) ReceiptInApps {
+	var list ReceiptInApps
+	for _, i := range positions {
+		list = append(list, idx.inApps[i])
+	}
+	return list
+}
+
+// receiptIndex is the lazily built index of Receipt
+type receiptIndex struct {
+	once   sync.Once
+	inApps *ReceiptInAppIndex
+	latest *ReceiptInAppIndex
+}
+
+// EnableIndex makes the helpers of Receipt use the index of `in_app` and `latest_receipt_info`
+// instead of scanning them for each call. It's efficient for the receipt with long subscription history.
+// The index is built lazily on the first call of the helpers,
+// so InApps and LatestReceiptInfo must not be modified after that.
+// EnableIndex must be called before the receipt is shared between goroutines,
+// and then the helpers can be called concurrently.
+func (r *Receipt) EnableIndex() {
+	if r.index == nil {
+		r.index = &receiptIndex{}
+	}
+}
+
+func (r *Receipt) inAppQuery() receiptInAppQuery {
+	if idx := r.buildIndex(); idx != nil {
+		return idx.inApps
+	}
+	return r.InApps
+}
+
+func (r *Receipt) latestReceiptInfoQuery() receiptInAppQuery {
+	if idx := r.buildIndex(); idx != nil {
+		return idx.latest
+	}
+	return r.LatestReceiptInfo
+}
+
+func (r *Receipt) buildIndex() *receiptIndex {
+	idx := r.index
+	if idx == nil {
+		return nil
+	}
+	idx.once.Do(func() {
+		idx.inApps = NewReceiptInAppIndex(r.InApps)
+		idx.latest = NewReceiptInAppIndex(r.LatestReceiptInfo)
+	})
+	return idx
+}
@@ -0,0 +1,189 @@
+package appstore
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testIndexBaseTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testReceiptWithHistory returns the receipt of monthly subscriber with n renewals
+func testReceiptWithHistory(n int) *Receipt {
+	r := &Receipt{}
+	for i := 0; i < n; i++ {
+		product := "com.example.app.subscription_1"
+		if i%3 == 0 {
+			product = "com.example.app.subscription_12"
+		}
+		purchase := testIndexBaseTime.AddDate(0, i, 0)
+		inApp := &ReceiptInApp{
+			ProductID:             product,
+			TransactionID:         int64(1000 + i),
+			OriginalTransactionID: int64(1000 + i%3),
+			PurchaseDate:          purchase,
+			ExpiresDate:           purchase.AddDate(0, 1, 0),
+		}
+		r.InApps = append(r.InApps, inApp)
+		r.LatestReceiptInfo = append(r.LatestReceiptInfo, inApp)
+		if i%10 == 0 {
+			// consumable
+			r.InApps = append(r.InApps, &ReceiptInApp{
+				ProductID:     "com.example.app.consumable",
+				TransactionID: int64(100000 + i),
+				PurchaseDate:  purchase,
+			})
+		}
+	}
+	// duplicated transaction_id and same expires_date
+	r.InApps = append(r.InApps,
+		&ReceiptInApp{ProductID: "com.example.app.other", TransactionID: 1001, ExpiresDate: testIndexBaseTime},
+		&ReceiptInApp{ProductID: "com.example.app.subscription_1", TransactionID: 999, ExpiresDate: r.InApps[1].ExpiresDate},
+	)
+	return r
+}
+
+func testIndexReceipts() []*Receipt {
+	return []*Receipt{
+		testReceipt1, testReceipt2, testReceipt3,
+		testReceiptIOS6_1, testReceiptIOS6_2, testReceiptIOS6_3,
+		testReceiptWithHistory(50),
+		{},
+	}
+}
+
+func testIndexProducts(r *Receipt) []string {
+	products := []string{"invalid_id"}
+	for _, list := range []ReceiptInApps{r.InApps, r.LatestReceiptInfo} {
+		for _, v := range list {
+			products = append(products, v.ProductID)
+		}
+	}
+	return products
+}
+
+func testIndexTimes() []time.Time {
+	return []time.Time{
+		time.Time{},
+		time.Date(2015, 12, 7, 23, 30, 0, 0, time.UTC),
+		testIndexBaseTime.AddDate(0, 20, 0),
+		time.Now(),
+	}
+}
+
+func TestReceiptInAppIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, r := range testIndexReceipts() {
+		for _, list := range []ReceiptInApps{r.InApps, r.LatestReceiptInfo} {
+			idx := NewReceiptInAppIndex(list)
+			ids := append(list.TransactionIDs(), 0, 1001, 1001)
+
+			assert.Equal(list.TransactionIDs(), idx.TransactionIDs())
+			assert.Equal(list.LastExpiresByTransactionIDs(ids), idx.LastExpiresByTransactionIDs(ids))
+			assert.Equal(list.LastExpiresByTransactionIDsForLatest(ids), idx.LastExpiresByTransactionIDsForLatest(ids))
+			assert.Equal(list.LastExpiresByTransactionIDs(ids[:1]), idx.LastExpiresByTransactionIDs(ids[:1]))
+			assert.Equal(list.LastExpiresByTransactionIDsForLatest(nil), idx.LastExpiresByTransactionIDsForLatest(nil))
+			for _, id := range ids {
+				assert.Equal(list.ByTransactionID(id), idx.ByTransactionID(id))
+				assert.Equal(list.ByOriginalTransactionID(id), idx.ByOriginalTransactionID(id))
+			}
+			for _, product := range testIndexProducts(r) {
+				assert.Equal(list.ByProduct(product), idx.ByProduct(product))
+				assert.Equal(list.TransactionIDsByProduct(product), idx.TransactionIDsByProduct(product))
+				assert.Equal(list.LastExpiresByProductID(product), idx.LastExpiresByProductID(product))
+				assert.Equal(list.LastExpiresByProductIDForLatest(product), idx.LastExpiresByProductIDForLatest(product))
+				assert.Equal(list.firstByProduct(product), idx.firstByProduct(product))
+			}
+			for _, now := range testIndexTimes() {
+				assert.Equal(list.withoutExpired(now), idx.withoutExpired(now))
+			}
+		}
+	}
+}
+
+func TestReceiptEnableIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, r := range testIndexReceipts() {
+		indexed := *r
+		indexed.EnableIndex()
+		ids := r.GetTransactionIDs()
+
+		assert.Equal(r.GetTransactionIDs(), indexed.GetTransactionIDs())
+		assert.Equal(r.GetTransactionIDsWithoutExpired(), indexed.GetTransactionIDsWithoutExpired())
+		assert.Equal(r.GetLastExpiresByTransactionIDs(ids), indexed.GetLastExpiresByTransactionIDs(ids))
+		for _, id := range ids {
+			assert.Equal(r.GetByTransactionID(id), indexed.GetByTransactionID(id))
+		}
+		for _, product := range testIndexProducts(r) {
+			assert.Equal(r.GetTransactionIDsByProduct(product), indexed.GetTransactionIDsByProduct(product))
+			assert.Equal(r.GetTransactionIDsByProductWithoutExpired(product), indexed.GetTransactionIDsByProductWithoutExpired(product))
+			assert.Equal(r.GetLastExpiresByProductID(product), indexed.GetLastExpiresByProductID(product))
+		}
+		for _, now := range testIndexTimes() {
+			assert.Equal(r.GetTransactionIDsWithoutExpiredAt(now), indexed.GetTransactionIDsWithoutExpiredAt(now))
+		}
+	}
+}
+
+func TestReceiptEnableIndexConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testReceiptWithHistory(100)
+	expected := testReceiptWithHistory(100).GetTransactionIDs()
+
+	// the index is built by the first helper in any goroutine
+	r.EnableIndex()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(expected, r.GetTransactionIDs())
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkReceiptHelpers(b *testing.B) {
+	const product = "com.example.app.subscription_1"
+	benchmarks := []struct {
+		name string
+		fn   func(r *Receipt)
+	}{
+		{"GetByTransactionID", func(r *Receipt) { r.GetByTransactionID(1500) }},
+		{"GetTransactionIDsByProduct", func(r *Receipt) { r.GetTransactionIDsByProduct(product) }},
+		{"GetLastExpiresByProductID", func(r *Receipt) { r.GetLastExpiresByProductID(product) }},
+		{"GetLastExpiresByTransactionIDs", func(r *Receipt) { r.GetLastExpiresByTransactionIDs([]int64{1100, 1200, 1300}) }},
+		{"GetTransactionIDsByProductWithoutExpired", func(r *Receipt) { r.GetTransactionIDsByProductWithoutExpired(product) }},
+	}
+
+	linear := testReceiptWithHistory(600)
+	indexed := testReceiptWithHistory(600)
+	indexed.EnableIndex()
+	for _, bm := range benchmarks {
+		b.Run(bm.name+"/linear", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bm.fn(linear)
+			}
+		})
+		b.Run(bm.name+"/index", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bm.fn(indexed)
+			}
+		})
+	}
+}
+
+func BenchmarkNewReceiptInAppIndex(b *testing.B) {
+	r := testReceiptWithHistory(600)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewReceiptInAppIndex(r.InApps)
+	}
+}
@@ -46,3 +46,13 @@ func (r ReceiptPendingRenewalInfos) IsAutoRenewStatusOff(productID string) bool
 	}
 	return false
 }
+
+// byProductID returns ReceiptPendingRenewalInfo of the current product id
+func (r ReceiptPendingRenewalInfos) byProductID(productID string) *ReceiptPendingRenewalInfo {
+	for _, v := range r {
+		if v.ProductID == productID {
+			return v
+		}
+	}
+	return nil
+}
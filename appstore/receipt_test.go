@@ -415,6 +415,65 @@ func TestGetTransactionIDsByProductWithoutExpired(t *testing.T) {
 	}
 }
 
+func TestGetTransactionIDsWithoutExpiredAt(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		receipt     *Receipt
+		at          time.Time
+		expectedLen int
+		expected1st int
+	}{
+		{testReceipt1, time.Date(2015, 12, 7, 23, 0, 0, 0, time.UTC), 14, 1000000183882899},
+		{testReceipt1, time.Date(2015, 12, 8, 0, 0, 0, 0, time.UTC), 2, 1000000183886962},
+		{testReceipt2, time.Date(2013, 3, 18, 6, 20, 0, 0, time.UTC), 1, 1000000068359170},
+		{testReceipt2, time.Date(2013, 3, 18, 6, 30, 0, 0, time.UTC), 0, 0},
+	}
+
+	for _, tt := range tests {
+		ids := tt.receipt.GetTransactionIDsWithoutExpiredAt(tt.at)
+		assert.Len(ids, tt.expectedLen)
+		if tt.expectedLen > 0 {
+			assert.EqualValues(tt.expected1st, ids[0])
+		}
+	}
+}
+
+func TestGetTransactionIDsByProductWithoutExpiredAt(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		receipt     *Receipt
+		product     string
+		at          time.Time
+		expectedLen int
+		expected1st int
+	}{
+		{testReceipt1, "com.example.app.subscription_1.v2", time.Date(2015, 12, 7, 23, 0, 0, 0, time.UTC), 6, 1000000183882899},
+		{testReceipt1, "com.example.app.subscription_1.v2", time.Date(2015, 12, 8, 0, 0, 0, 0, time.UTC), 0, 0},
+		{testReceipt1, "com.example.app.subscription_long", time.Date(2015, 12, 8, 0, 0, 0, 0, time.UTC), 1, 1000000183886963},
+		{testReceipt1, "invalid_id", time.Date(2015, 12, 7, 23, 0, 0, 0, time.UTC), 0, 0},
+	}
+
+	for _, tt := range tests {
+		ids := tt.receipt.GetTransactionIDsByProductWithoutExpiredAt(tt.product, tt.at)
+		assert.Len(ids, tt.expectedLen)
+		if tt.expectedLen > 0 {
+			assert.EqualValues(tt.expected1st, ids[0])
+		}
+	}
+}
+
+func TestReceiptInAppIsExpiredAt(t *testing.T) {
+	assert := assert.New(t)
+
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.False((&ReceiptInApp{}).IsExpiredAt(at))
+	assert.False((&ReceiptInApp{ExpiresDate: at}).IsExpiredAt(at))
+	assert.False((&ReceiptInApp{ExpiresDate: at.Add(time.Second)}).IsExpiredAt(at))
+	assert.True((&ReceiptInApp{ExpiresDate: at.Add(-time.Second)}).IsExpiredAt(at))
+}
+
 func TestReceiptPendingRenewalInfoIsAutoRenewStatusOn(t *testing.T) {
 	assert := assert.New(t)
 
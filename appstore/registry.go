@@ -0,0 +1,124 @@
+package appstore
+
+import (
+	"errors"
+	"sync"
+)
+
+const statusSharedSecretMismatch = 21004
+
+var (
+	// ErrUnregisteredBundleID is returned when the bundle id of the receipt is not registered
+	ErrUnregisteredBundleID = errors.New("The bundle id in the receipt is not registered.")
+	// ErrEnvironmentNotAllowed is returned when the environment of the receipt is not allowed for the app
+	ErrEnvironmentNotAllowed = errors.New("The environment of the receipt is not allowed.")
+)
+
+// AppConfig is a configuration of each app in Registry
+type AppConfig struct {
+	BundleID     string
+	SharedSecret string
+	// Environments is a list of allowed environments of the receipt (e.g. "Production", "Sandbox").
+	// All of environments are allowed when it's empty.
+	Environments []string
+}
+
+// IsAllowedEnvironment checks the environment is allowed for the app or not.
+// Empty environment (iOS6 style receipt) is always allowed.
+func (a AppConfig) IsAllowedEnvironment(env string) bool {
+	if len(a.Environments) == 0 || env == "" {
+		return true
+	}
+	return containsString(a.Environments, env)
+}
+
+// Registry has configurations of multiple apps sharing one backend,
+// and selects the shared secret for each receipt.
+type Registry struct {
+	mu    sync.RWMutex
+	apps  map[string]AppConfig
+	order []string
+}
+
+// NewRegistry creates Registry with the apps
+func NewRegistry(apps ...AppConfig) *Registry {
+	r := &Registry{
+		apps: make(map[string]AppConfig),
+	}
+	for _, app := range apps {
+		r.Register(app)
+	}
+	return r
+}
+
+// Register adds or replaces the configuration of the app
+func (r *Registry) Register(app AppConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.apps[app.BundleID]; !ok {
+		r.order = append(r.order, app.BundleID)
+	}
+	r.apps[app.BundleID] = app
+}
+
+// Get returns the configuration of the app
+func (r *Registry) Get(bundleID string) (AppConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	app, ok := r.apps[bundleID]
+	return app, ok
+}
+
+// candidates returns configurations to try for the bundle id.
+// All of apps are returned in registered order when the bundle id is unknown.
+func (r *Registry) candidates(bundleID string) []AppConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if app, ok := r.apps[bundleID]; ok {
+		return []AppConfig{app}
+	}
+	list := make([]AppConfig, 0, len(r.order))
+	for _, id := range r.order {
+		list = append(list, r.apps[id])
+	}
+	return list
+}
+
+// Verify sends the receipt with the shared secret of the app.
+// When bundleID is empty or unknown, the shared secrets of all apps are tried until it's accepted.
+// The receipt is rejected when its bundle id is not registered or its environment is not allowed.
+func (r *Registry) Verify(client IAPClient, receiptData, bundleID string) (*Receipt, error) {
+	var resp *Receipt
+	for _, app := range r.candidates(bundleID) {
+		var err error
+		resp, err = client.Verify(IAPRequest{
+			ReceiptData: receiptData,
+			Password:    app.SharedSecret,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status != statusSharedSecretMismatch {
+			break
+		}
+	}
+
+	switch {
+	case resp == nil:
+		return nil, ErrUnregisteredBundleID
+	case resp.HasError() != nil:
+		// invalid receipt does not have bundle id
+		return resp, nil
+	}
+
+	app, ok := r.Get(resp.BundleID)
+	switch {
+	case !ok:
+		return nil, ErrUnregisteredBundleID
+	case bundleID != "" && bundleID != resp.BundleID:
+		return nil, ErrReceiptBundleIDMismatch
+	case !app.IsAllowedEnvironment(resp.Environment):
+		return nil, ErrEnvironmentNotAllowed
+	}
+	return resp, nil
+}
@@ -0,0 +1,119 @@
+package appstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testIAPClient returns the receipt for the shared secret
+type testIAPClient struct {
+	receipts  map[string]*Receipt
+	passwords []string
+	err       error
+}
+
+func (c *testIAPClient) Verify(req IAPRequest) (*Receipt, error) {
+	c.passwords = append(c.passwords, req.Password)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if r, ok := c.receipts[req.Password]; ok {
+		return r, nil
+	}
+	return &Receipt{Status: 21004}, nil
+}
+
+func testRegistry() *Registry {
+	return NewRegistry(
+		AppConfig{BundleID: "com.example.app1", SharedSecret: "secret1"},
+		AppConfig{BundleID: "com.example.app2", SharedSecret: "secret2", Environments: []string{"Production"}},
+		AppConfig{BundleID: "com.example.app3", SharedSecret: "secret3"},
+	)
+}
+
+func TestRegistryGet(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testRegistry()
+	app, ok := r.Get("com.example.app2")
+	assert.True(ok)
+	assert.Equal("secret2", app.SharedSecret)
+
+	_, ok = r.Get("com.example.unknown")
+	assert.False(ok)
+
+	r.Register(AppConfig{BundleID: "com.example.app2", SharedSecret: "secret2-new"})
+	app, _ = r.Get("com.example.app2")
+	assert.Equal("secret2-new", app.SharedSecret)
+	assert.Len(r.candidates(""), 3)
+}
+
+func TestAppConfigIsAllowedEnvironment(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(AppConfig{}.IsAllowedEnvironment("Sandbox"))
+
+	app := AppConfig{Environments: []string{"Production"}}
+	assert.True(app.IsAllowedEnvironment("Production"))
+	assert.True(app.IsAllowedEnvironment(""))
+	assert.False(app.IsAllowedEnvironment("Sandbox"))
+}
+
+func TestRegistryVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testRegistry()
+	client := &testIAPClient{receipts: map[string]*Receipt{
+		"secret2": {Status: 0, Environment: "Production", BundleID: "com.example.app2"},
+	}}
+
+	// known bundle id uses its secret only
+	resp, err := r.Verify(client, "receipt", "com.example.app2")
+	assert.NoError(err)
+	assert.Equal("com.example.app2", resp.BundleID)
+	assert.Equal([]string{"secret2"}, client.passwords)
+
+	// unknown bundle id tries all of secrets
+	client.passwords = nil
+	resp, err = r.Verify(client, "receipt", "")
+	assert.NoError(err)
+	assert.Equal("com.example.app2", resp.BundleID)
+	assert.Equal([]string{"secret1", "secret2"}, client.passwords)
+
+	// bundle id differs from the receipt
+	_, err = r.Verify(client, "receipt", "com.example.unknown")
+	assert.Equal(ErrReceiptBundleIDMismatch, err)
+
+	// no secret is accepted
+	client.receipts = nil
+	resp, err = r.Verify(client, "receipt", "")
+	assert.NoError(err)
+	assert.Equal(21004, resp.Status)
+}
+
+func TestRegistryVerifyRejects(t *testing.T) {
+	assert := assert.New(t)
+
+	r := testRegistry()
+
+	client := &testIAPClient{receipts: map[string]*Receipt{
+		"secret1": {Status: 0, Environment: "Production", BundleID: "com.example.other"},
+	}}
+	_, err := r.Verify(client, "receipt", "")
+	assert.Equal(ErrUnregisteredBundleID, err)
+
+	client = &testIAPClient{receipts: map[string]*Receipt{
+		"secret2": {Status: 0, Environment: "Sandbox", BundleID: "com.example.app2"},
+	}}
+	_, err = r.Verify(client, "receipt", "com.example.app2")
+	assert.Equal(ErrEnvironmentNotAllowed, err)
+
+	client = &testIAPClient{err: errors.New("network error")}
+	_, err = r.Verify(client, "receipt", "com.example.app1")
+	assert.EqualError(err, "network error")
+
+	_, err = NewRegistry().Verify(client, "receipt", "")
+	assert.Equal(ErrUnregisteredBundleID, err)
+}
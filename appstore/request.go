@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/http/httputil"
 	"time"
 
@@ -21,6 +22,21 @@ import (
 // post sends POST request with option.
 func post(url string, opt option) (*response, error) {
 	opt.URL = url
+	opt.Method = "POST"
+	return call(opt)
+}
+
+// get sends GET request with option.
+func get(url string, opt option) (*response, error) {
+	opt.URL = url
+	opt.Method = "GET"
+	return call(opt)
+}
+
+// put sends PUT request with option.
+func put(url string, opt option) (*response, error) {
+	opt.URL = url
+	opt.Method = "PUT"
 	return call(opt)
 }
 
@@ -29,15 +45,26 @@ func call(opt option) (*response, error) {
 	cli.URL(opt.URL)
 
 	// Define a custom header
-	cli.Use(gtls.Config(&tls.Config{InsecureSkipVerify: true}))
+	tlsConfig := opt.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	cli.Use(gtls.Config(tlsConfig))
 
 	req := cli.Request()
-	req.Method("POST")
+	req.Method(opt.Method)
+	for k, v := range opt.Header {
+		req.SetHeader(k, v)
+	}
 
 	// Set timeout
 	if opt.hasTimeout() {
 		req.Use(timeout.Request(opt.Timeout))
 	}
+	// Count requests including retries
+	if opt.Attempts != nil {
+		req.Use(countAttempts(opt.Attempts))
+	}
 	// Set retry (3 times)
 	if opt.Retry {
 		req.Use(retry.New(retry.ConstantBackoff))
@@ -65,10 +92,18 @@ func call(opt option) (*response, error) {
 // option is wrapper struct of http option
 type option struct {
 	URL     string
+	Method  string
+	Header  map[string]string
 	Timeout time.Duration
 	Retry   bool
 	Debug   bool
 
+	// Attempts is set to the number of sent requests including retries
+	Attempts *int
+
+	// TLSConfig verifies the server certificate when it's set
+	TLSConfig *tls.Config
+
 	// POST Parameter
 	Payload interface{}
 }
@@ -86,6 +121,30 @@ type response struct {
 	*gentleman.Response
 }
 
+// countAttempts counts the requests sent by the transport.
+// It should be used before retry plugin to count each retry.
+func countAttempts(count *int) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(ctx *context.Context, h context.Handler) {
+		transport := ctx.Client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		ctx.Client.Transport = attemptCounter{transport: transport, count: count}
+		h.Next(ctx)
+	})
+}
+
+// attemptCounter is http.RoundTripper to count the requests
+type attemptCounter struct {
+	transport http.RoundTripper
+	count     *int
+}
+
+func (t attemptCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	*t.count++
+	return t.transport.RoundTrip(req)
+}
+
 func debugRequest() plugin.Plugin {
 	p := plugin.New()
 	p.SetHandler("before dial", func(ctx *context.Context, h context.Handler) {
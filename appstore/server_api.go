@@ -0,0 +1,279 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ServerAPISandboxURL    string = "https://api.storekit-sandbox.itunes.apple.com"
+	ServerAPIProductionURL string = "https://api.storekit.itunes.apple.com"
+
+	serverAPIAudience      = "appstoreconnect-v1"
+	serverAPITokenLifetime = 20 * time.Minute
+)
+
+// ErrInvalidOrderID is returned when the order id is not found by the App Store
+var ErrInvalidOrderID = errors.New("The order id is invalid.")
+
+// ServerAPIConfig is a configuration to initialize client of App Store Server API
+type ServerAPIConfig struct {
+	// KeyID is the key id of the private key from App Store Connect
+	KeyID string
+	// IssuerID is the issuer id from the Keys page in App Store Connect
+	IssuerID string
+	// BundleID is the bundle id of the app
+	BundleID string
+	// PrivateKey is the content of the private key file (.p8) from App Store Connect
+	PrivateKey []byte
+	// RootCertificates verifies certificate chain of signed payloads (required).
+	// Use Apple Root CA - G3 for the App Store (see LoadRootCertificates).
+	RootCertificates *x509.CertPool
+	// TLSConfig is used to connect to the App Store Server API.
+	// When this is nil, the server certificate is verified with the system roots.
+	TLSConfig *tls.Config
+	// ExtensionHistory is used to check the limit of renewal date extensions.
+	// When this is nil, the history is kept on memory.
+	ExtensionHistory ExtensionHistory
+
+	IsProduction bool
+	TimeOut      time.Duration
+	Retry        bool
+	Debug        bool
+}
+
+// ServerAPIClient is a client of App Store Server API
+// see: https://developer.apple.com/documentation/appstoreserverapi
+type ServerAPIClient struct {
+	URL     string
+	TimeOut time.Duration
+	Retry   bool
+	Debug   bool
+
+	keyID      string
+	issuerID   string
+	bundleID   string
+	privateKey *ecdsa.PrivateKey
+	roots      *x509.CertPool
+	tlsConfig  *tls.Config
+	extensions ExtensionHistory
+}
+
+// ServerAPIError is returned when App Store Server API responds with error status
+type ServerAPIError struct {
+	StatusCode   int    `json:"-"`
+	ErrorCode    int64  `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+func (e *ServerAPIError) Error() string {
+	if e.ErrorMessage == "" {
+		return "An error occurred in App Store Server API - code:" + strconv.Itoa(e.StatusCode)
+	}
+	return "An error occurred in App Store Server API - code:" + strconv.Itoa(e.StatusCode) + ", " + strconv.FormatInt(e.ErrorCode, 10) + ": " + e.ErrorMessage
+}
+
+// NewServerAPIClient creates a client of App Store Server API with configuration
+func NewServerAPIClient(config ServerAPIConfig) (*ServerAPIClient, error) {
+	if config.RootCertificates == nil {
+		return nil, ErrNoRootCertificates
+	}
+	key, err := parsePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if config.TimeOut == 0 {
+		config.TimeOut = time.Second * 5
+	}
+	if config.ExtensionHistory == nil {
+		config.ExtensionHistory = NewMemoryExtensionHistory()
+	}
+
+	client := &ServerAPIClient{
+		URL:        ServerAPISandboxURL,
+		TimeOut:    config.TimeOut,
+		Retry:      config.Retry,
+		Debug:      config.Debug,
+		keyID:      config.KeyID,
+		issuerID:   config.IssuerID,
+		bundleID:   config.BundleID,
+		privateKey: key,
+		roots:      config.RootCertificates,
+		tlsConfig:  config.TLSConfig,
+		extensions: config.ExtensionHistory,
+	}
+	if config.IsProduction {
+		client.URL = ServerAPIProductionURL
+	}
+	return client, nil
+}
+
+// parsePrivateKey parses ES256 private key in PEM format,
+// which is PKCS#8 as the key file of App Store Connect, or SEC 1 ("EC PRIVATE KEY")
+func parsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("The private key is not PEM format.")
+	}
+	if block.Type == "EC PRIVATE KEY" {
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("The private key is not ECDSA key.")
+	}
+	return ecKey, nil
+}
+
+// newUUID returns random UUID (version 4) in lowercase
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// token creates JSON Web Token to authorize the request
+// see: https://developer.apple.com/documentation/appstoreserverapi/generating_tokens_for_api_requests
+func (c *ServerAPIClient) token() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{
+		"alg": "ES256",
+		"kid": c.keyID,
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": c.issuerID,
+		"iat": now.Unix(),
+		"exp": now.Add(serverAPITokenLifetime).Unix(),
+		"aud": serverAPIAudience,
+		"bid": c.bundleID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := signES256(c.privateKey, []byte(unsigned))
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// do sends request to App Store Server API and decodes the response into result.
+func (c *ServerAPIClient) do(send func(string, option) (*response, error), path string, payload, result interface{}) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	resp, err := send(c.URL+path, option{
+		Header:    map[string]string{"Authorization": "Bearer " + token},
+		Timeout:   c.TimeOut,
+		Retry:     c.Retry,
+		Debug:     c.Debug,
+		TLSConfig: c.tlsConfig,
+		Payload:   payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	body := resp.Bytes()
+	if !resp.Ok {
+		apiErr := &ServerAPIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, apiErr)
+		return apiErr
+	}
+	if result == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// parseSignedTransactions verifies and converts signed transactions into ReceiptInApps
+func (c *ServerAPIClient) parseSignedTransactions(signed []string) (ReceiptInApps, error) {
+	var rc ReceiptInApps
+	for _, v := range signed {
+		tx, err := ParseSignedTransaction(v, c.roots)
+		if err != nil {
+			return nil, err
+		}
+		rc = append(rc, tx.ToReceiptInApp())
+	}
+	return rc, nil
+}
+
+// LookUpOrderID returns transactions of the order id from the customer's invoice
+// see: https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+func (c *ServerAPIClient) LookUpOrderID(orderID string) (ReceiptInApps, error) {
+	var result struct {
+		Status             int      `json:"status"`
+		SignedTransactions []string `json:"signedTransactions"`
+	}
+	err := c.do(get, "/inApps/v1/lookup/"+url.PathEscape(orderID), nil, &result)
+	switch {
+	case err != nil:
+		return nil, err
+	case result.Status != 0:
+		return nil, ErrInvalidOrderID
+	}
+	return c.parseSignedTransactions(result.SignedTransactions)
+}
+
+// GetRefundHistory returns all of refunded transactions for the customer of the transaction id
+// see: https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+func (c *ServerAPIClient) GetRefundHistory(transactionID string) (ReceiptInApps, error) {
+	var rc ReceiptInApps
+	revision := ""
+	for {
+		path := "/inApps/v2/refund/lookup/" + url.PathEscape(transactionID)
+		if revision != "" {
+			path += "?revision=" + url.QueryEscape(revision)
+		}
+
+		var result struct {
+			SignedTransactions []string `json:"signedTransactions"`
+			Revision           string   `json:"revision"`
+			HasMore            bool     `json:"hasMore"`
+		}
+		if err := c.do(get, path, nil, &result); err != nil {
+			return nil, err
+		}
+
+		txs, err := c.parseSignedTransactions(result.SignedTransactions)
+		if err != nil {
+			return nil, err
+		}
+		rc = append(rc, txs...)
+
+		if !result.HasMore || result.Revision == "" {
+			return rc, nil
+		}
+		revision = result.Revision
+	}
+}
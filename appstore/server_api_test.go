@@ -0,0 +1,419 @@
+package appstore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSigner signs JWS payloads as the App Store does, with its own root certificate.
+type testSigner struct {
+	key   *ecdsa.PrivateKey
+	x5c   []string
+	roots *x509.CertPool
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	return newTestSignerWithExtensions(t, true)
+}
+
+// newTestSignerWithExtensions creates the chain of root, intermediate and leaf certificates.
+// When appleExtensions is false, the certificates don't have the extensions of Apple.
+func newTestSignerWithExtensions(t *testing.T, appleExtensions bool) *testSigner {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, roots := newTestCertChain(t, leafKey, appleExtensions)
+	x5c := make([]string, len(certs))
+	for i, cert := range certs {
+		x5c[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	return &testSigner{key: leafKey, x5c: x5c, roots: roots}
+}
+
+// newTestCertChain issues the leaf certificate of the key under the new root and intermediate certificates,
+// and returns the certificates (leaf, intermediate, root) with the pool of the root
+func newTestCertChain(t *testing.T, leafKey crypto.Signer, appleExtensions bool) ([]*x509.Certificate, *x509.CertPool) {
+	issue := func(template, parent *x509.Certificate, key, parentKey crypto.Signer, oid asn1.ObjectIdentifier) *x509.Certificate {
+		// valid at the creation date of the test receipts
+		template.NotBefore = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		template.NotAfter = time.Now().Add(time.Hour)
+		if appleExtensions && oid != nil {
+			template.ExtraExtensions = []pkix.Extension{{Id: oid, Value: asn1.NullBytes}}
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	newKey := func() crypto.Signer {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	rootKey, intermediateKey := newKey(), newKey()
+	root := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, rootKey, nil, nil)
+	intermediate := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, intermediateKey, rootKey, oidAppleWWDRIntermediate)
+	leaf := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, intermediate, leafKey, intermediateKey, oidAppleStoreKitLeaf)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return []*x509.Certificate{leaf, intermediate, root}, roots
+}
+
+func (s *testSigner) sign(t *testing.T, payload interface{}) string {
+	header, _ := json.Marshal(map[string]interface{}{"alg": "ES256", "x5c": s.x5c})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	sig, err := signES256(s.key, []byte(unsigned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testPrivateKeyPEM(t *testing.T) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: testMarshalPKCS8(t, key)}), key
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPEM, key := testPrivateKeyPEM(t)
+	parsed, err := parsePrivateKey(keyPEM)
+	assert.NoError(err)
+	assert.Equal(key.D, parsed.D)
+
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(err)
+	parsed, err = parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	assert.NoError(err)
+	assert.Equal(key.D, parsed.D)
+
+	_, err = parsePrivateKey([]byte("not pem"))
+	assert.Error(err)
+}
+
+// testMarshalPKCS8 marshals the key in PKCS#8 as the key file of App Store Connect.
+// x509.MarshalPKCS8PrivateKey is not available in Go 1.9.
+func testMarshalPKCS8(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	ecKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}) // P-256
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		Version    int
+		Algorithm  pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}, // id-ecPublicKey
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		PrivateKey: ecKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func testServerAPITools(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *ServerAPIClient, *testSigner) {
+	signer := newTestSigner(t)
+	keyPEM, _ := testPrivateKeyPEM(t)
+	client, err := NewServerAPIClient(ServerAPIConfig{
+		KeyID:            "KEYID",
+		IssuerID:         "issuer",
+		BundleID:         "com.example.app",
+		PrivateKey:       keyPEM,
+		RootCertificates: signer.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	client.URL = server.URL
+	return server, client, signer
+}
+
+func TestNewServerAPIClient(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPEM, _ := testPrivateKeyPEM(t)
+	roots := newTestSigner(t).roots
+	client, err := NewServerAPIClient(ServerAPIConfig{PrivateKey: keyPEM, RootCertificates: roots})
+	assert.NoError(err)
+	assert.Equal(ServerAPISandboxURL, client.URL)
+	assert.Equal(time.Second*5, client.TimeOut)
+	assert.False(client.tlsConfig.InsecureSkipVerify)
+
+	client, err = NewServerAPIClient(ServerAPIConfig{PrivateKey: keyPEM, RootCertificates: roots, IsProduction: true, TimeOut: time.Second})
+	assert.NoError(err)
+	assert.Equal(ServerAPIProductionURL, client.URL)
+	assert.Equal(time.Second, client.TimeOut)
+
+	_, err = NewServerAPIClient(ServerAPIConfig{PrivateKey: []byte("invalid"), RootCertificates: roots})
+	assert.Error(err)
+
+	_, err = NewServerAPIClient(ServerAPIConfig{PrivateKey: keyPEM})
+	assert.Equal(ErrNoRootCertificates, err)
+}
+
+func TestServerAPIClientTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":0}`))
+	}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	keyPEM, _ := testPrivateKeyPEM(t)
+	client, err := NewServerAPIClient(ServerAPIConfig{PrivateKey: keyPEM, RootCertificates: newTestSigner(t).roots})
+	assert.NoError(err)
+	client.URL = server.URL
+	_, err = client.LookUpOrderID("ORDER")
+	assert.Error(err, "the certificate of the test server is not trusted")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client, err = NewServerAPIClient(ServerAPIConfig{
+		PrivateKey:       keyPEM,
+		RootCertificates: newTestSigner(t).roots,
+		TLSConfig:        &tls.Config{RootCAs: pool},
+	})
+	assert.NoError(err)
+	client.URL = server.URL
+	_, err = client.LookUpOrderID("ORDER")
+	assert.NoError(err)
+}
+
+func TestServerAPIClientToken(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPEM, key := testPrivateKeyPEM(t)
+	client, err := NewServerAPIClient(ServerAPIConfig{
+		KeyID:            "KEYID",
+		IssuerID:         "issuer",
+		BundleID:         "com.example.app",
+		PrivateKey:       keyPEM,
+		RootCertificates: newTestSigner(t).roots,
+	})
+	assert.NoError(err)
+
+	token, err := client.token()
+	assert.NoError(err)
+	parts := strings.Split(token, ".")
+	assert.Len(parts, 3)
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Len(sig, 64)
+	assert.True(verifyES256(&key.PublicKey, []byte(parts[0]+"."+parts[1]), sig))
+
+	var claims map[string]interface{}
+	body, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(json.Unmarshal(body, &claims))
+	assert.Equal("issuer", claims["iss"])
+	assert.Equal("appstoreconnect-v1", claims["aud"])
+	assert.Equal("com.example.app", claims["bid"])
+}
+
+func TestParseSignedTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestSigner(t)
+	signed := signer.sign(t, map[string]interface{}{
+		"transactionId":         "2000000001",
+		"originalTransactionId": "2000000000",
+		"productId":             "com.example.product.sub",
+		"purchaseDate":          1582000000000,
+		"expiresDate":           1584600000000,
+		"quantity":              1,
+		"offerDiscountType":     "FREE_TRIAL",
+	})
+
+	tx, err := ParseSignedTransaction(signed, signer.roots)
+	assert.NoError(err)
+	assert.Equal("2000000001", tx.TransactionID)
+
+	inApp := tx.ToReceiptInApp()
+	assert.Equal(int64(2000000001), inApp.TransactionID)
+	assert.Equal(int64(2000000000), inApp.OriginalTransactionID)
+	assert.Equal(int64(1), inApp.Quantity)
+	assert.True(inApp.IsTrialPeriod)
+	assert.Equal(time.Unix(1582000000, 0), inApp.PurchaseDate)
+	assert.Equal(time.Unix(1584600000, 0), inApp.ExpiresDate)
+	assert.True(inApp.CancellationDate.IsZero())
+
+	// without roots
+	_, err = ParseSignedTransaction(signed, nil)
+	assert.Equal(ErrNoRootCertificates, err)
+
+	// unknown root
+	_, err = ParseSignedTransaction(signed, newTestSigner(t).roots)
+	assert.Error(err)
+
+	// the chain without the extensions of Apple
+	other := newTestSignerWithExtensions(t, false)
+	_, err = ParseSignedTransaction(other.sign(t, map[string]string{"transactionId": "1"}), other.roots)
+	assert.Equal(ErrInvalidJWSCertificate, err)
+
+	// tampered payload
+	parts := strings.Split(signed, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"transactionId":"1"}`))
+	_, err = ParseSignedTransaction(strings.Join(parts, "."), signer.roots)
+	assert.Equal(ErrInvalidJWSSignature, err)
+
+	_, err = ParseSignedTransaction("invalid", signer.roots)
+	assert.Equal(ErrInvalidJWS, err)
+}
+
+func TestLoadRootCertificates(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := newTestSigner(t)
+	rootDER, _ := base64.StdEncoding.DecodeString(signer.x5c[2])
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER})
+	signed := signer.sign(t, map[string]string{"transactionId": "1"})
+
+	for _, data := range [][]byte{rootDER, rootPEM} {
+		roots, err := LoadRootCertificates(data)
+		assert.NoError(err)
+		_, err = ParseSignedTransaction(signed, roots)
+		assert.NoError(err)
+	}
+
+	_, err := LoadRootCertificates([]byte("invalid"))
+	assert.Error(err)
+}
+
+func TestLookUpOrderID(t *testing.T) {
+	assert := assert.New(t)
+
+	var signer *testSigner
+	server, client, signer := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		assert.True(strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "))
+		switch r.URL.Path {
+		case "/inApps/v1/lookup/MQ0000000":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": 0,
+				"signedTransactions": []string{
+					signer.sign(t, map[string]interface{}{"transactionId": "2000000001", "productId": "com.example.product.item"}),
+					signer.sign(t, map[string]interface{}{"transactionId": "2000000002", "productId": "com.example.product.item2"}),
+				},
+			})
+		case "/inApps/v1/lookup/INVALID":
+			fmt.Fprint(w, `{"status":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errorCode":4040010,"errorMessage":"Transaction id not found."}`)
+		}
+	})
+	defer server.Close()
+
+	inApps, err := client.LookUpOrderID("MQ0000000")
+	assert.NoError(err)
+	assert.Equal([]int64{2000000001, 2000000002}, inApps.TransactionIDs())
+
+	_, err = client.LookUpOrderID("INVALID")
+	assert.Equal(ErrInvalidOrderID, err)
+
+	_, err = client.LookUpOrderID("NOTFOUND")
+	apiErr, ok := err.(*ServerAPIError)
+	assert.True(ok)
+	assert.Equal(http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(int64(4040010), apiErr.ErrorCode)
+}
+
+func TestGetRefundHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	var signer *testSigner
+	server, client, signer := testServerAPITools(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/inApps/v2/refund/lookup/2000000000", r.URL.Path)
+		switch r.URL.Query().Get("revision") {
+		case "":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"signedTransactions": []string{
+					signer.sign(t, map[string]interface{}{"transactionId": "2000000001", "revocationDate": 1582000000000}),
+				},
+				"revision": "rev1",
+				"hasMore":  true,
+			})
+		case "rev1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"signedTransactions": []string{
+					signer.sign(t, map[string]interface{}{"transactionId": "2000000002", "revocationDate": 1583000000000}),
+				},
+				"revision": "rev2",
+				"hasMore":  false,
+			})
+		}
+	})
+	defer server.Close()
+
+	inApps, err := client.GetRefundHistory("2000000000")
+	assert.NoError(err)
+	assert.Equal([]int64{2000000001, 2000000002}, inApps.TransactionIDs())
+	assert.Equal(time.Unix(1583000000, 0), inApps[1].CancellationDate)
+}
@@ -0,0 +1,207 @@
+package appstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPlist is returned when the transaction receipt is not the old-style ASCII plist
+var ErrInvalidPlist = errors.New("The transaction receipt is not a valid plist.")
+
+// TransactionReceiptIOS6 is the transaction receipt of iOS 6 style (SKPaymentTransaction.transactionReceipt),
+// which is the old-style ASCII plist having purchase-info and signature.
+type TransactionReceiptIOS6 struct {
+	Signature     string
+	Environment   string
+	Pod           string
+	SigningStatus string
+	PurchaseInfo  ReceiptIOS6
+}
+
+// ParseTransactionReceiptIOS6 decodes the receipt data of iOS 6 style (base64 encoded plist)
+// without sending it to the App Store.
+// Note that the signature is not verified.
+func ParseTransactionReceiptIOS6(receiptData string) (*TransactionReceiptIOS6, error) {
+	raw, err := base64.StdEncoding.DecodeString(receiptData)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := parsePlistDict(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := base64.StdEncoding.DecodeString(dict["purchase-info"])
+	if err != nil {
+		return nil, err
+	}
+	infoDict, err := parsePlistDict(string(info))
+	if err != nil {
+		return nil, err
+	}
+
+	// keys in purchase-info are the same as JSON response of verifyReceipt except for the separator
+	fields := make(map[string]string, len(infoDict))
+	for k, v := range infoDict {
+		fields[strings.Replace(k, "-", "_", -1)] = v
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &TransactionReceiptIOS6{
+		Signature:     dict["signature"],
+		Environment:   dict["environment"],
+		Pod:           dict["pod"],
+		SigningStatus: dict["signing-status"],
+	}
+	if err := json.Unmarshal(b, &r.PurchaseInfo); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parsePlistDict parses the dictionary of string values in old-style ASCII (NeXTSTEP) plist.
+func parsePlistDict(s string) (map[string]string, error) {
+	p := &plistParser{s: s}
+	p.skipSpace()
+	if !p.consume('{') {
+		return nil, ErrInvalidPlist
+	}
+
+	dict := make(map[string]string)
+	for {
+		p.skipSpace()
+		if p.consume('}') {
+			break
+		}
+		key, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume('=') {
+			return nil, ErrInvalidPlist
+		}
+		p.skipSpace()
+		value, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(';') {
+			return nil, ErrInvalidPlist
+		}
+		dict[key] = value
+	}
+
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, ErrInvalidPlist
+	}
+	return dict, nil
+}
+
+type plistParser struct {
+	s   string
+	pos int
+}
+
+func (p *plistParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.s)
+				return
+			}
+			p.pos += end + 4
+		case strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *plistParser) consume(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *plistParser) readString() (string, error) {
+	if p.consume('"') {
+		return p.readQuoted()
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && isPlistUnquotedChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", ErrInvalidPlist
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *plistParser) readQuoted() (string, error) {
+	var b bytes.Buffer
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos >= len(p.s) {
+				return "", ErrInvalidPlist
+			}
+			e := p.s[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'U':
+				if p.pos+4 > len(p.s) {
+					return "", ErrInvalidPlist
+				}
+				r, err := strconv.ParseUint(p.s[p.pos:p.pos+4], 16, 16)
+				if err != nil {
+					return "", ErrInvalidPlist
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", ErrInvalidPlist
+}
+
+func isPlistUnquotedChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("_$+/:.-", c) >= 0
+}
@@ -0,0 +1,105 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPurchaseInfoIOS6 = `{
+	"original-purchase-date-pst" = "2015-05-09 16:31:28 America/Los_Angeles";
+	"purchase-date-ms" = "1431214285000";
+	"unique-identifier" = "0000b0000000000000000000000000000000000";
+	"original-transaction-id" = "90000000000001";
+	"expires-date" = "1433892685000";
+	"transaction-id" = "90000000000002";
+	"original-purchase-date-ms" = "1431214288000";
+	"web-order-line-item-id" = "70000000000001";
+	"bvrs" = "0.1";
+	"expires-date-formatted-pst" = "2015-06-09 16:31:25 America/Los_Angeles";
+	"item-id" = "900000001";
+	"expires-date-formatted" = "2015-06-09 23:31:25 Etc/GMT";
+	"product-id" = "com.example.product.item";
+	"purchase-date" = "2015-05-09 23:31:25 Etc/GMT";
+	"original-purchase-date" = "2015-05-09 23:31:28 Etc/GMT";
+	"bid" = "com.example.app";
+	"purchase-date-pst" = "2015-05-09 16:31:25 America/Los_Angeles";
+	"is-trial-period" = "false";
+	"quantity" = "1";
+}`
+
+func testTransactionReceiptIOS6(purchaseInfo string) string {
+	plist := `{
+	"signature" = "AApMyE2Y1KbXW3jtYOuXvAkTfrlc/5Q==";
+	"purchase-info" = "` + base64.StdEncoding.EncodeToString([]byte(purchaseInfo)) + `";
+	/* comment */
+	environment = Sandbox;
+	"pod" = "100";
+	"signing-status" = "0";
+}`
+	return base64.StdEncoding.EncodeToString([]byte(plist))
+}
+
+func TestParseTransactionReceiptIOS6(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := ParseTransactionReceiptIOS6(testTransactionReceiptIOS6(testPurchaseInfoIOS6))
+	assert.NoError(err)
+	assert.Equal("AApMyE2Y1KbXW3jtYOuXvAkTfrlc/5Q==", r.Signature)
+	assert.Equal("Sandbox", r.Environment)
+	assert.Equal("100", r.Pod)
+	assert.Equal("0", r.SigningStatus)
+
+	info := r.PurchaseInfo
+	assert.Equal("com.example.app", info.BundleID)
+	assert.Equal("0.1", info.ApplicationVersion)
+	assert.Equal("com.example.product.item", info.ProductID)
+	assert.Equal("90000000000002", info.TransactionID)
+	assert.Equal("90000000000001", info.OriginalTransactionID)
+	assert.Equal("70000000000001", info.WebOrderLineItemID)
+	assert.Equal("1", info.Quantity)
+	assert.Equal("1433892685000", info.ExpiresDateMS)
+	assert.Equal("2015-06-09 23:31:25 Etc/GMT", info.ExpiresDate)
+	assert.Equal("1431214285000", info.PurchaseDate.PurchaseDateMS)
+	assert.Equal("2015-05-09 23:31:28 Etc/GMT", info.OriginalPurchaseDate.OriginalPurchaseDate)
+
+	// same conversion as the response of verifyReceipt
+	inApp := ToReceiptInApp(info.ToInApp())
+	assert.Equal(int64(90000000000002), inApp.TransactionID)
+	assert.Equal(time.Unix(1433892685, 0), inApp.ExpiresDate)
+}
+
+func TestParseTransactionReceiptIOS6Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ParseTransactionReceiptIOS6("not base64!")
+	assert.Error(err)
+
+	tests := []string{
+		`"key" = "value";`,
+		`{ "key" "value"; }`,
+		`{ "key" = "value" }`,
+		`{ "key" = "value`,
+		`{ "key" = "value"; } trailing`,
+	}
+	for _, tt := range tests {
+		_, err := ParseTransactionReceiptIOS6(base64.StdEncoding.EncodeToString([]byte(tt)))
+		assert.Equal(ErrInvalidPlist, err, tt)
+	}
+
+	// purchase-info is broken
+	_, err = ParseTransactionReceiptIOS6(testTransactionReceiptIOS6(`{ "bid" = `))
+	assert.Equal(ErrInvalidPlist, err)
+}
+
+func TestParsePlistDict(t *testing.T) {
+	assert := assert.New(t)
+
+	dict, err := parsePlistDict(`{ "a\"b" = "line\nnext\U00e9"; plain = 1.0; // comment
+}`)
+	assert.NoError(err)
+	assert.Equal("line\nnexté", dict[`a"b`])
+	assert.Equal("1.0", dict["plain"])
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/evalphobia/go-iap/observer"
 )
 
 const (
@@ -19,6 +21,18 @@ type Config struct {
 	TimeOut      time.Duration
 	Retry        bool
 	Debug        bool
+	// Policy is applied to the valid receipt in Verify when it's set
+	Policy *Policy
+	// Cache stores the valid receipts in Verify when it's set
+	Cache Cache
+	// CacheTTL is the maximum duration to cache the receipt (default: 1h)
+	CacheTTL time.Duration
+	// CircuitBreaker fails fast with ErrCircuitOpen during the outage of App Store when it's set
+	CircuitBreaker *CircuitBreaker
+	// Observer receives the event of each Verify call when it's set
+	Observer observer.Observer
+	// PrecheckReceipt rejects the malformed receipt data by CheckReceiptData before sending it to the App Store
+	PrecheckReceipt bool
 }
 
 // IAPClient is an interface to call validation API in App Store
@@ -32,6 +46,15 @@ type Client struct {
 	TimeOut time.Duration
 	Retry   bool
 	Debug   bool
+	Policy  *Policy
+
+	Cache    Cache
+	CacheTTL time.Duration
+
+	CircuitBreaker *CircuitBreaker
+	Observer       observer.Observer
+
+	PrecheckReceipt bool
 }
 
 // HandleError returns error message by status code
@@ -87,6 +110,19 @@ func NewWithConfig(config Config) Client {
 		TimeOut: config.TimeOut,
 		Retry:   config.Retry,
 		Debug:   config.Debug,
+		Policy:  config.Policy,
+
+		CircuitBreaker: config.CircuitBreaker,
+		Observer:       config.Observer,
+
+		PrecheckReceipt: config.PrecheckReceipt,
+	}
+	if config.Cache != nil {
+		client.Cache = config.Cache
+		client.CacheTTL = config.CacheTTL
+		if client.CacheTTL == 0 {
+			client.CacheTTL = defaultCacheTTL
+		}
 	}
 	if config.IsProduction {
 		client.URL = ProductionURL
@@ -95,35 +131,138 @@ func NewWithConfig(config Config) Client {
 	return client
 }
 
-// Verify sends receipts and gets validation result
+// Verify sends receipts and gets validation result.
+// When Cache is set, the valid receipt is returned from the cache for the same request.
+// When Policy is set and the receipt violates it, the receipt is returned with *PolicyError.
+// When PrecheckReceipt is set and the receipt data is malformed, *ReceiptDataError is returned without any request,
+// and the error is sent to Observer.
 func (c *Client) Verify(req IAPRequest) (*Receipt, error) {
+	start := time.Now()
+	event := observer.Event{
+		Store:    observer.StoreAppStore,
+		Endpoint: c.URL,
+	}
+	if c.PrecheckReceipt {
+		if err := CheckReceiptData(req.ReceiptData); err != nil {
+			c.observe(event, start, nil, err)
+			return nil, err
+		}
+	}
+
+	receipt, err := c.verifyWithCache(req, &event)
+	c.observe(event, start, receipt, err)
+
+	switch {
+	case err != nil:
+		return nil, err
+	case c.Policy == nil || receipt.HasError() != nil:
+		return receipt, nil
+	}
+	return receipt, c.Policy.Validate(receipt)
+}
+
+func (c *Client) verifyWithCache(req IAPRequest, event *observer.Event) (*Receipt, error) {
+	if c.Cache == nil {
+		return c.verifyWithBreaker(req, event)
+	}
+
+	key := cacheKey(req)
+	if receipt, ok := c.Cache.Get(key); ok {
+		event.CacheHit = true
+		return receipt.shallowCopy(), nil
+	}
+	receipt, err := c.verifyWithBreaker(req, event)
+	if err != nil || !isCacheable(receipt) {
+		return receipt, err
+	}
+	if ttl := cacheTTL(receipt, c.CacheTTL, time.Now()); ttl > 0 {
+		c.Cache.Set(key, receipt.shallowCopy(), ttl)
+	}
+	return receipt, nil
+}
+
+func (c *Client) verifyWithBreaker(req IAPRequest, event *observer.Event) (*Receipt, error) {
+	if c.CircuitBreaker == nil {
+		return c.verify(req, event)
+	}
+
+	generation, err := c.CircuitBreaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := c.verify(req, event)
+	c.CircuitBreaker.done(generation, isBreakerFailure(receipt, err))
+	return receipt, err
+}
+
+func (c *Client) verify(req IAPRequest, event *observer.Event) (*Receipt, error) {
+	attempts := 0
 	resp, err := post(c.URL, option{
-		Payload: req,
-		Timeout: c.TimeOut,
-		Retry:   c.Retry,
-		Debug:   c.Debug,
+		Payload:  req,
+		Timeout:  c.TimeOut,
+		Retry:    c.Retry,
+		Debug:    c.Debug,
+		Attempts: &attempts,
 	})
+	if attempts > 1 {
+		event.Retries = attempts - 1
+	}
 	switch {
 	case err != nil:
 		return nil, err
 	case !resp.Ok:
-		return nil, errors.New("An error occurred in IAP - code:" + strconv.Itoa(resp.StatusCode))
+		event.HTTPStatus = resp.StatusCode
+		return nil, &httpStatusError{StatusCode: resp.StatusCode}
 	}
 
-	body := resp.Bytes()
+	event.HTTPStatus = resp.StatusCode
+	return decodeIAPResponse(resp.Bytes(), req.ReceiptData)
+}
+
+// httpStatusError is returned when verifyReceipt responds with the error status
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return "An error occurred in IAP - code:" + strconv.Itoa(e.StatusCode)
+}
 
+// isTransientError checks the error of Verify is caused by the network or the outage of App Store.
+// The errors caused by the request or the response body, such as *PolicyError and *ReceiptDataError, are not.
+func isTransientError(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case *httpStatusError:
+		return e.StatusCode >= 500
+	case *PolicyError, *ReceiptDataError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	}
+	// network errors
+	return true
+}
+
+// decodeIAPResponse decodes the response of verifyReceipt.
+// iOS 7 style response, which has `environment`, is decoded only once,
+// and the response is decoded again as iOS 6 style otherwise.
+//
+// Decoding the body in one pass doesn't pay off: `receipt` and `latest_receipt_info` differ between the formats,
+// so they must be kept raw until `environment` is read and then decoded again,
+// which is slower than decoding iOS 7 style response at once (see BenchmarkDecodeIAPResponse).
+func decodeIAPResponse(body []byte, rawReceipt string) (*Receipt, error) {
 	// iOS7 formant
 	result := IAPResponseIOS7{
-		rawReceipt: req.ReceiptData,
+		rawReceipt: rawReceipt,
 	}
-	err = json.Unmarshal(body, &result)
+	err := json.Unmarshal(body, &result)
 	if err == nil && result.Environment != "" {
 		return result.ToReceipt(), nil
 	}
 
 	// iOS6 formant
 	resultIOS6 := IAPResponseIOS6{
-		rawReceipt: req.ReceiptData,
+		rawReceipt: rawReceipt,
 	}
 	err = json.Unmarshal(body, &resultIOS6)
 	if err != nil {
@@ -131,3 +270,17 @@ func (c *Client) Verify(req IAPRequest) (*Receipt, error) {
 	}
 	return resultIOS6.ToIOS7().ToReceipt(), nil
 }
+
+// observe sends the event of Verify to Observer
+func (c *Client) observe(event observer.Event, start time.Time, receipt *Receipt, err error) {
+	if c.Observer == nil {
+		return
+	}
+	event.Latency = time.Since(start)
+	event.Err = err
+	if receipt != nil {
+		event.Environment = receipt.Environment
+		event.Status = receipt.Status
+	}
+	c.Observer.Observe(event)
+}
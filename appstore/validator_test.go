@@ -10,6 +10,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/evalphobia/go-iap/observer"
 )
 
 func TestHandleError(t *testing.T) {
@@ -155,10 +157,14 @@ func TestVerifyErrors(t *testing.T) {
 		ReceiptData: "dummy data",
 	}
 
-	expected := errors.New("An error occurred in IAP - code:199")
+	expected := "An error occurred in IAP - code:199"
 	_, actual := client.Verify(req)
-	if !reflect.DeepEqual(actual, expected) {
+	if actual == nil || actual.Error() != expected {
 		t.Errorf("got %v\nwant %v", actual, expected)
+		return
+	}
+	if e, ok := actual.(*httpStatusError); !ok || e.StatusCode != 199 {
+		t.Errorf("got %#v\nwant *httpStatusError", actual)
 	}
 }
 
@@ -183,6 +189,64 @@ func TestVerifyTimeout(t *testing.T) {
 	}
 }
 
+func TestVerifyObserver(t *testing.T) {
+	server, client := testTools(200, testReceiptString)
+	defer server.Close()
+
+	obs := observer.NewCountingObserver()
+	client.Observer = obs
+	client.Cache = NewLRUCache(10)
+
+	req := IAPRequest{
+		ReceiptData: "dummy data",
+	}
+	client.Verify(req)
+	client.Verify(req)
+
+	events := obs.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %v\nwant %v", len(events), 2)
+	}
+	e := events[0]
+	expected := observer.Event{
+		Store:       observer.StoreAppStore,
+		Endpoint:    server.URL,
+		Environment: "Sandbox",
+		Latency:     e.Latency,
+		HTTPStatus:  200,
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("got %v\nwant %v", e, expected)
+	}
+	if e.Latency <= 0 {
+		t.Errorf("got %v\nwant positive latency", e.Latency)
+	}
+	if !events[1].CacheHit || events[1].HTTPStatus != 0 {
+		t.Errorf("got %v\nwant cache hit", events[1])
+	}
+}
+
+func TestVerifyObserverErrors(t *testing.T) {
+	server, client := testTools(500, "dummy response")
+	defer server.Close()
+
+	obs := observer.NewCountingObserver()
+	client.Observer = obs
+
+	req := IAPRequest{
+		ReceiptData: "dummy data",
+	}
+	_, err := client.Verify(req)
+
+	events := obs.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %v\nwant %v", len(events), 1)
+	}
+	if events[0].HTTPStatus != 500 || events[0].Err != err {
+		t.Errorf("got %v\nwant HTTP status 500 and %v", events[0], err)
+	}
+}
+
 func testTools(code int, body string) (*httptest.Server, *Client) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
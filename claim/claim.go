@@ -0,0 +1,166 @@
+// Package claim records the transactions against the users to prevent replay of the receipts.
+//
+// A transaction id of the App Store, or a purchase token or order id of Google Play
+// is claimed by the first user who sends it, and reported when the other user sends it again.
+package claim
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	NamespaceAppStore       = "appstore"
+	NamespacePlayStoreToken = "playstore.token"
+	NamespacePlayStoreOrder = "playstore.order"
+)
+
+// ErrEmptyUserID is returned when the user id is empty
+var ErrEmptyUserID = errors.New("The user id is empty.")
+
+// Key identifies the transaction in TransactionStore
+type Key struct {
+	Namespace string
+	ID        string
+}
+
+func (k Key) String() string {
+	return k.Namespace + ":" + k.ID
+}
+
+// AppStoreTransaction returns Key of the transaction id of the App Store
+func AppStoreTransaction(transactionID int64) Key {
+	return Key{Namespace: NamespaceAppStore, ID: strconv.FormatInt(transactionID, 10)}
+}
+
+// AppStoreTransactions returns Keys of the transaction ids of the App Store
+func AppStoreTransactions(transactionIDs []int64) []Key {
+	keys := make([]Key, len(transactionIDs))
+	for i, id := range transactionIDs {
+		keys[i] = AppStoreTransaction(id)
+	}
+	return keys
+}
+
+// PlayStorePurchaseToken returns Key of the purchase token of Google Play
+func PlayStorePurchaseToken(token string) Key {
+	return Key{Namespace: NamespacePlayStoreToken, ID: token}
+}
+
+// PlayStoreOrder returns Key of the order id of Google Play
+func PlayStoreOrder(orderID string) Key {
+	return Key{Namespace: NamespacePlayStoreOrder, ID: orderID}
+}
+
+// Record is the transaction claimed by the user
+type Record struct {
+	Key       Key
+	UserID    string
+	ClaimedAt time.Time
+}
+
+// TransactionStore is an interface to store the claimed transactions.
+// Claim must be atomic: when the same key is claimed concurrently, only one of the calls creates the record.
+type TransactionStore interface {
+	// Claim creates the record of the key for the user when it doesn't exist,
+	// and returns the record in the store and whether it's created by this call or not.
+	Claim(key Key, userID string, at time.Time) (Record, bool, error)
+	// Get returns the record of the key
+	Get(key Key) (Record, bool, error)
+	// Release deletes the record of the key (e.g. for refunded transactions)
+	Release(key Key) error
+}
+
+// Status is the result of the claim
+type Status int
+
+const (
+	// StatusClaimed means the transaction is claimed by the user for the first time
+	StatusClaimed Status = iota
+	// StatusAlreadyClaimed means the transaction was claimed by the same user before
+	StatusAlreadyClaimed
+	// StatusClaimedByOther means the transaction was claimed by the other user before
+	StatusClaimedByOther
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusClaimed:
+		return "claimed"
+	case StatusAlreadyClaimed:
+		return "already-claimed"
+	case StatusClaimedByOther:
+		return "claimed-by-other"
+	}
+	return "unknown"
+}
+
+// Result is the result of Claim.
+// Record has the owner of the transaction, which is the other user when Status is StatusClaimedByOther.
+type Result struct {
+	Record
+	Status Status
+}
+
+// IsNew checks the transaction is claimed by this call or not
+func (r Result) IsNew() bool {
+	return r.Status == StatusClaimed
+}
+
+// Claim records the transaction against the user and reports who has claimed it
+func Claim(store TransactionStore, userID string, key Key) (Result, error) {
+	if userID == "" {
+		return Result{}, ErrEmptyUserID
+	}
+
+	record, created, err := store.Claim(key, userID, time.Now())
+	switch {
+	case err != nil:
+		return Result{}, err
+	case created:
+		return Result{Record: record, Status: StatusClaimed}, nil
+	case record.UserID == userID:
+		return Result{Record: record, Status: StatusAlreadyClaimed}, nil
+	}
+	return Result{Record: record, Status: StatusClaimedByOther}, nil
+}
+
+// Results is a list of Result
+type Results []Result
+
+// ClaimAll claims the transactions in order.
+// When an error occurs, the results until then are returned with the error.
+func ClaimAll(store TransactionStore, userID string, keys ...Key) (Results, error) {
+	results := make(Results, 0, len(keys))
+	for _, key := range keys {
+		r, err := Claim(store, userID, key)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// New returns the keys of the transactions claimed by this call
+func (rs Results) New() []Key {
+	var keys []Key
+	for _, r := range rs {
+		if r.IsNew() {
+			keys = append(keys, r.Key)
+		}
+	}
+	return keys
+}
+
+// ClaimedByOther returns the results of the transactions claimed by the other users
+func (rs Results) ClaimedByOther() Results {
+	var list Results
+	for _, r := range rs {
+		if r.Status == StatusClaimedByOther {
+			list = append(list, r)
+		}
+	}
+	return list
+}
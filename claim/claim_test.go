@@ -0,0 +1,97 @@
+package claim
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("appstore:1000000001", AppStoreTransaction(1000000001).String())
+	assert.Equal([]Key{
+		{Namespace: NamespaceAppStore, ID: "1"},
+		{Namespace: NamespaceAppStore, ID: "2"},
+	}, AppStoreTransactions([]int64{1, 2}))
+	assert.Equal("playstore.token:abc", PlayStorePurchaseToken("abc").String())
+	assert.Equal("playstore.order:GPA.1234", PlayStoreOrder("GPA.1234").String())
+	// same id in the different namespaces
+	assert.NotEqual(PlayStorePurchaseToken("1"), AppStoreTransaction(1))
+}
+
+func TestClaim(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryStore()
+	key := AppStoreTransaction(1000000001)
+
+	r, err := Claim(store, "user1", key)
+	assert.NoError(err)
+	assert.Equal(StatusClaimed, r.Status)
+	assert.True(r.IsNew())
+	assert.Equal("user1", r.UserID)
+
+	r, err = Claim(store, "user1", key)
+	assert.NoError(err)
+	assert.Equal(StatusAlreadyClaimed, r.Status)
+	assert.False(r.IsNew())
+
+	r, err = Claim(store, "user2", key)
+	assert.NoError(err)
+	assert.Equal(StatusClaimedByOther, r.Status)
+	assert.Equal("user1", r.UserID)
+	assert.Equal("claimed-by-other", r.Status.String())
+
+	_, err = Claim(store, "", key)
+	assert.Equal(ErrEmptyUserID, err)
+}
+
+func TestClaimAll(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryStore()
+	Claim(store, "user1", AppStoreTransaction(1))
+	Claim(store, "user2", AppStoreTransaction(2))
+
+	results, err := ClaimAll(store, "user1", AppStoreTransactions([]int64{1, 2, 3})...)
+	assert.NoError(err)
+	assert.Len(results, 3)
+	assert.Equal([]Key{AppStoreTransaction(3)}, results.New())
+	others := results.ClaimedByOther()
+	assert.Len(others, 1)
+	assert.Equal(AppStoreTransaction(2), others[0].Key)
+	assert.Equal("user2", others[0].UserID)
+}
+
+func TestClaimConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryStore()
+	key := PlayStoreOrder("GPA.1234")
+
+	var mu sync.Mutex
+	var winners []string
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			r, err := Claim(store, userID, key)
+			assert.NoError(err)
+			if r.IsNew() {
+				mu.Lock()
+				winners = append(winners, userID)
+				mu.Unlock()
+			}
+		}("user" + strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	assert.Len(winners, 1)
+	r, ok, _ := store.Get(key)
+	assert.True(ok)
+	assert.Equal(winners[0], r.UserID)
+}
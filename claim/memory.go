@@ -0,0 +1,55 @@
+package claim
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory TransactionStore.
+// The records are lost when the process exits, so this is for tests and single process applications.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[Key]Record
+}
+
+// NewMemoryStore creates MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[Key]Record),
+	}
+}
+
+// Claim creates the record of the key for the user when it doesn't exist
+func (s *MemoryStore) Claim(key Key, userID string, at time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[key]; ok {
+		return r, false, nil
+	}
+	r := Record{
+		Key:       key,
+		UserID:    userID,
+		ClaimedAt: at,
+	}
+	s.records[key] = r
+	return r, true, nil
+}
+
+// Get returns the record of the key
+func (s *MemoryStore) Get(key Key) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	return r, ok, nil
+}
+
+// Release deletes the record of the key
+func (s *MemoryStore) Release(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,33 @@
+package claim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewMemoryStore()
+	now := time.Now()
+	key := PlayStorePurchaseToken("token")
+
+	_, ok, err := s.Get(key)
+	assert.NoError(err)
+	assert.False(ok)
+
+	r, created, err := s.Claim(key, "user1", now)
+	assert.NoError(err)
+	assert.True(created)
+	assert.Equal(Record{Key: key, UserID: "user1", ClaimedAt: now}, r)
+
+	r, created, _ = s.Claim(key, "user2", now.Add(time.Hour))
+	assert.False(created)
+	assert.Equal("user1", r.UserID)
+
+	assert.NoError(s.Release(key))
+	_, created, _ = s.Claim(key, "user2", now)
+	assert.True(created)
+}
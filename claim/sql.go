@@ -0,0 +1,103 @@
+package claim
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// SQLStore is TransactionStore on database/sql.
+// The primary key of (namespace, transaction_id) makes Claim atomic; CreateTable creates the table.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+
+	// Placeholder returns the placeholder of the n-th argument starting from 1.
+	// The default is "?" for MySQL and SQLite, and use DollarPlaceholder for PostgreSQL.
+	Placeholder func(n int) string
+}
+
+// NewSQLStore creates SQLStore on the table.
+// The table name is used in the queries as it is, so it must not be taken from user input.
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	return &SQLStore{
+		db:          db,
+		table:       table,
+		Placeholder: questionPlaceholder,
+	}
+}
+
+func questionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder returns the placeholder for PostgreSQL (e.g. $1)
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// CreateTable creates the table when it doesn't exist
+func (s *SQLStore) CreateTable() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	namespace VARCHAR(32) NOT NULL,
+	transaction_id VARCHAR(512) NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	claimed_at BIGINT NOT NULL,
+	PRIMARY KEY (namespace, transaction_id)
+)`)
+	return err
+}
+
+// Claim creates the record of the key for the user when it doesn't exist.
+// When the insert fails by the primary key, the existing record is returned.
+func (s *SQLStore) Claim(key Key, userID string, at time.Time) (Record, bool, error) {
+	query := "INSERT INTO " + s.table + " (namespace, transaction_id, user_id, claimed_at) VALUES (" +
+		s.Placeholder(1) + ", " + s.Placeholder(2) + ", " + s.Placeholder(3) + ", " + s.Placeholder(4) + ")"
+	_, err := s.db.Exec(query, key.Namespace, key.ID, userID, at.Unix())
+	if err == nil {
+		return Record{
+			Key:       key,
+			UserID:    userID,
+			ClaimedAt: time.Unix(at.Unix(), 0),
+		}, true, nil
+	}
+
+	// the error is not caused by the duplicate key when the record doesn't exist
+	r, ok, getErr := s.Get(key)
+	switch {
+	case getErr != nil:
+		return Record{}, false, getErr
+	case !ok:
+		return Record{}, false, err
+	}
+	return r, false, nil
+}
+
+// Get returns the record of the key
+func (s *SQLStore) Get(key Key) (Record, bool, error) {
+	query := "SELECT user_id, claimed_at FROM " + s.table +
+		" WHERE namespace = " + s.Placeholder(1) + " AND transaction_id = " + s.Placeholder(2)
+
+	var userID string
+	var claimedAt int64
+	err := s.db.QueryRow(query, key.Namespace, key.ID).Scan(&userID, &claimedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return Record{}, false, nil
+	case err != nil:
+		return Record{}, false, err
+	}
+	return Record{
+		Key:       key,
+		UserID:    userID,
+		ClaimedAt: time.Unix(claimedAt, 0),
+	}, true, nil
+}
+
+// Release deletes the record of the key
+func (s *SQLStore) Release(key Key) error {
+	query := "DELETE FROM " + s.table +
+		" WHERE namespace = " + s.Placeholder(1) + " AND transaction_id = " + s.Placeholder(2)
+	_, err := s.db.Exec(query, key.Namespace, key.ID)
+	return err
+}
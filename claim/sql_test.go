@@ -0,0 +1,189 @@
+package claim
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDriver is a database/sql driver which understands the queries of SQLStore only
+type testDriver struct {
+	mu      sync.Mutex
+	rows    map[[2]string][]driver.Value
+	queries []string
+}
+
+var testSQLDriver = &testDriver{}
+
+func init() {
+	sql.Register("claimtest", testSQLDriver)
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	return &testConn{d: d}, nil
+}
+
+func (d *testDriver) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rows = make(map[[2]string][]driver.Value)
+	d.queries = nil
+}
+
+type testConn struct {
+	d *testDriver
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{d: c.d, query: query}, nil
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transaction is not supported")
+}
+
+type testStmt struct {
+	d     *testDriver
+	query string
+}
+
+func (s *testStmt) Close() error {
+	return nil
+}
+
+func (s *testStmt) NumInput() int {
+	return -1
+}
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	if strings.Contains(s.query, "broken") {
+		return nil, errors.New("no such table")
+	}
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		key := [2]string{args[0].(string), args[1].(string)}
+		if _, ok := s.d.rows[key]; ok {
+			return nil, errors.New("duplicate key")
+		}
+		s.d.rows[key] = []driver.Value{args[2], args[3]}
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.d.rows, [2]string{args[0].(string), args[1].(string)})
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	rows := &testRows{}
+	if row, ok := s.d.rows[[2]string{args[0].(string), args[1].(string)}]; ok {
+		rows.values = append(rows.values, row)
+	}
+	return rows, nil
+}
+
+type testRows struct {
+	values [][]driver.Value
+	i      int
+}
+
+func (r *testRows) Columns() []string {
+	return []string{"user_id", "claimed_at"}
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.i])
+	r.i++
+	return nil
+}
+
+func testSQLStore(t *testing.T, table string) *SQLStore {
+	testSQLDriver.reset()
+	db, err := sql.Open("claimtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLStore(db, table)
+}
+
+func TestSQLStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testSQLStore(t, "iap_claims")
+	assert.NoError(s.CreateTable())
+	assert.Contains(testSQLDriver.queries[0], "CREATE TABLE IF NOT EXISTS iap_claims")
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := AppStoreTransaction(1000000001)
+	r, created, err := s.Claim(key, "user1", now)
+	assert.NoError(err)
+	assert.True(created)
+	assert.Equal("user1", r.UserID)
+	assert.True(now.Equal(r.ClaimedAt))
+
+	r, created, err = s.Claim(key, "user2", now.Add(time.Hour))
+	assert.NoError(err)
+	assert.False(created)
+	assert.Equal(key, r.Key)
+	assert.Equal("user1", r.UserID)
+	assert.True(now.Equal(r.ClaimedAt))
+
+	assert.NoError(s.Release(key))
+	_, ok, err := s.Get(key)
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.Equal("INSERT INTO iap_claims (namespace, transaction_id, user_id, claimed_at) VALUES (?, ?, ?, ?)", testSQLDriver.queries[1])
+}
+
+func TestSQLStorePlaceholder(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testSQLStore(t, "iap_claims")
+	s.Placeholder = DollarPlaceholder
+	res, err := Claim(s, "user1", PlayStorePurchaseToken("token"))
+	assert.NoError(err)
+	assert.Equal(StatusClaimed, res.Status)
+	res, err = Claim(s, "user2", PlayStorePurchaseToken("token"))
+	assert.NoError(err)
+	assert.Equal(StatusClaimedByOther, res.Status)
+
+	assert.Equal([]string{
+		"INSERT INTO iap_claims (namespace, transaction_id, user_id, claimed_at) VALUES ($1, $2, $3, $4)",
+		"INSERT INTO iap_claims (namespace, transaction_id, user_id, claimed_at) VALUES ($1, $2, $3, $4)",
+		"SELECT user_id, claimed_at FROM iap_claims WHERE namespace = $1 AND transaction_id = $2",
+	}, testSQLDriver.queries)
+}
+
+func TestSQLStoreError(t *testing.T) {
+	assert := assert.New(t)
+
+	s := testSQLStore(t, "broken")
+	_, created, err := s.Claim(AppStoreTransaction(1), "user1", time.Now())
+	assert.EqualError(err, "no such table")
+	assert.False(created)
+}
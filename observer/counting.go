@@ -0,0 +1,91 @@
+package observer
+
+import (
+	"sync"
+)
+
+// CountingObserver is an in-memory Observer which keeps the events for tests
+type CountingObserver struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewCountingObserver creates CountingObserver
+func NewCountingObserver() *CountingObserver {
+	return &CountingObserver{}
+}
+
+// Observe records the event
+func (o *CountingObserver) Observe(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, e)
+}
+
+// Events returns the copy of the recorded events
+func (o *CountingObserver) Events() []Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	events := make([]Event, len(o.events))
+	copy(events, o.events)
+	return events
+}
+
+// Count returns the number of the recorded events
+func (o *CountingObserver) Count() int {
+	return o.CountIf(func(Event) bool {
+		return true
+	})
+}
+
+// CountIf returns the number of the recorded events matched with the function
+func (o *CountingObserver) CountIf(match func(Event) bool) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	count := 0
+	for _, e := range o.events {
+		if match(e) {
+			count++
+		}
+	}
+	return count
+}
+
+// CountByEndpoint returns the number of the recorded events of the endpoint
+func (o *CountingObserver) CountByEndpoint(endpoint string) int {
+	return o.CountIf(func(e Event) bool {
+		return e.Endpoint == endpoint
+	})
+}
+
+// Errors returns the number of the recorded events with error
+func (o *CountingObserver) Errors() int {
+	return o.CountIf(func(e Event) bool {
+		return e.Err != nil
+	})
+}
+
+// CacheHits returns the number of the recorded events returned from the cache
+func (o *CountingObserver) CacheHits() int {
+	return o.CountIf(func(e Event) bool {
+		return e.CacheHit
+	})
+}
+
+// Retries returns the total number of the retries
+func (o *CountingObserver) Retries() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	total := 0
+	for _, e := range o.events {
+		total += e.Retries
+	}
+	return total
+}
+
+// Reset removes the recorded events
+func (o *CountingObserver) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = nil
+}
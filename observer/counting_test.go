@@ -0,0 +1,40 @@
+package observer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingObserver(t *testing.T) {
+	assert := assert.New(t)
+
+	o := NewCountingObserver()
+	var obs Observer = o
+	obs.Observe(Event{Store: StoreAppStore, Endpoint: "verifyReceipt", Retries: 2})
+	obs.Observe(Event{Store: StoreAppStore, Endpoint: "verifyReceipt", CacheHit: true})
+	obs.Observe(Event{Store: StorePlayStore, Endpoint: "purchases.products.get", Err: errors.New("error")})
+
+	assert.Equal(3, o.Count())
+	assert.Equal(2, o.CountByEndpoint("verifyReceipt"))
+	assert.Equal(1, o.Errors())
+	assert.Equal(1, o.CacheHits())
+	assert.Equal(2, o.Retries())
+	assert.Equal(1, o.CountIf(func(e Event) bool { return e.Store == StorePlayStore }))
+	assert.Len(o.Events(), 3)
+
+	o.Reset()
+	assert.Equal(0, o.Count())
+}
+
+func TestFunc(t *testing.T) {
+	assert := assert.New(t)
+
+	var received Event
+	var obs Observer = Func(func(e Event) {
+		received = e
+	})
+	obs.Observe(Event{Endpoint: "verifyReceipt"})
+	assert.Equal("verifyReceipt", received.Endpoint)
+}
@@ -0,0 +1,49 @@
+// Package observer provides the hooks to observe the verification calls of appstore and playstore.
+// It has no dependency on any metrics library, so the events can be exported to any backend.
+package observer
+
+import (
+	"time"
+)
+
+// Store names of Event
+const (
+	StoreAppStore  = "appstore"
+	StorePlayStore = "playstore"
+)
+
+// Event is the result of each verification call
+type Event struct {
+	// Store is the name of the store (appstore or playstore)
+	Store string
+	// Endpoint is the URL or the name of the API
+	Endpoint string
+	// Environment is Production or Sandbox, empty when it's unknown
+	Environment string
+	Latency     time.Duration
+	// HTTPStatus is the status code of the last HTTP response, zero when no response is received
+	HTTPStatus int
+	// Status is the status of the receipt in App Store
+	Status int
+	// ErrorCode is the code of googleapi.Error in Google Play
+	ErrorCode int
+	// Retries is the number of retries of the HTTP request
+	Retries int
+	// CacheHit is true when the result is returned from the cache without calling API
+	CacheHit bool
+	Err      error
+}
+
+// Observer receives the events of the verification calls.
+// Observe is called synchronously, so it should not block.
+type Observer interface {
+	Observe(Event)
+}
+
+// Func is an adapter to use the function as Observer
+type Func func(Event)
+
+// Observe calls f(e)
+func (f Func) Observe(e Event) {
+	f(e)
+}
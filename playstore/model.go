@@ -56,11 +56,16 @@ func (r IABResponse) IsActive() bool {
 
 // IsExpired checks if the subscription has been already expired
 func (r IABResponse) IsExpired() bool {
+	return r.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt checks if the subscription has been already expired at the given time
+func (r IABResponse) IsExpiredAt(t time.Time) bool {
 	switch {
 	case !r.IsValidSubscription():
 		return false
 	default:
-		now := time.Now().UnixNano() / int64(time.Millisecond)
-		return r.SubscriptionPurchase.ExpiryTimeMillis < now
+		ms := t.UnixNano() / int64(time.Millisecond)
+		return r.SubscriptionPurchase.ExpiryTimeMillis < ms
 	}
 }
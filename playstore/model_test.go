@@ -0,0 +1,45 @@
+package playstore
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/androidpublisher/v3"
+)
+
+func TestIsExpiredAt(t *testing.T) {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := at.UnixNano() / int64(time.Millisecond)
+
+	tests := []struct {
+		expected bool
+		resp     IABResponse
+	}{
+		{false, IABResponse{}},
+		{false, IABResponse{ProductPurchase: &androidpublisher.ProductPurchase{}}},
+		{false, IABResponse{SubscriptionPurchase: &androidpublisher.SubscriptionPurchase{ExpiryTimeMillis: ms}}},
+		{false, IABResponse{SubscriptionPurchase: &androidpublisher.SubscriptionPurchase{ExpiryTimeMillis: ms + 1}}},
+		{true, IABResponse{SubscriptionPurchase: &androidpublisher.SubscriptionPurchase{ExpiryTimeMillis: ms - 1}}},
+	}
+
+	for _, v := range tests {
+		actual := v.resp.IsExpiredAt(at)
+		if actual != v.expected {
+			t.Errorf("got %v\nwant %v", actual, v.expected)
+		}
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	resp := IABResponse{SubscriptionPurchase: &androidpublisher.SubscriptionPurchase{ExpiryTimeMillis: now - 1000}}
+	if !resp.IsExpired() {
+		t.Errorf("got %v\nwant %v", false, true)
+	}
+
+	resp = IABResponse{SubscriptionPurchase: &androidpublisher.SubscriptionPurchase{ExpiryTimeMillis: now + 60000}}
+	if resp.IsExpired() {
+		t.Errorf("got %v\nwant %v", true, false)
+	}
+}
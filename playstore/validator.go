@@ -9,6 +9,9 @@ import (
 	"golang.org/x/oauth2/google"
 	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/androidpublisher/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/evalphobia/go-iap/observer"
 )
 
 const (
@@ -33,6 +36,9 @@ type IABClient interface {
 // The Client type implements VerifySubscription method
 type Client struct {
 	httpClient *http.Client
+
+	// Observer receives the event of each API call when it's set
+	Observer observer.Observer
 }
 
 // New returns http client which includes the credentials to access androidpublisher API.
@@ -45,7 +51,7 @@ func New(jsonKey []byte) (Client, error) {
 
 	conf, err := google.JWTConfigFromJSON(jsonKey, scope)
 
-	return Client{conf.Client(ctx)}, err
+	return Client{httpClient: conf.Client(ctx)}, err
 }
 
 func NewWithParams(key, email string) Client {
@@ -58,7 +64,7 @@ func NewWithParams(key, email string) Client {
 		Scopes:     []string{scope},
 		TokenURL:   google.JWTTokenURL,
 	}
-	return Client{conf.Client(ctx)}
+	return Client{httpClient: conf.Client(ctx)}
 }
 
 // Verify retrieves product and subscription status from GooglePlay API
@@ -83,9 +89,16 @@ func (c *Client) verifySubscription(packageName, subscriptionID, token string) (
 		return nil, err
 	}
 
+	start := time.Now()
 	ps := androidpublisher.NewPurchasesSubscriptionsService(service)
 	result, err := ps.Get(packageName, subscriptionID, token).Do()
 
+	event := observer.Event{Endpoint: "purchases.subscriptions.get"}
+	if result != nil {
+		event.HTTPStatus = result.HTTPStatusCode
+		event.Environment = purchaseEnvironment(result.PurchaseType)
+	}
+	c.observe(event, start, err)
 	return result, err
 }
 
@@ -101,9 +114,16 @@ func (c *Client) verifyProduct(packageName, productID, token string) (*androidpu
 		return nil, err
 	}
 
+	start := time.Now()
 	ps := androidpublisher.NewPurchasesProductsService(service)
 	result, err := ps.Get(packageName, productID, token).Do()
 
+	event := observer.Event{Endpoint: "purchases.products.get"}
+	if result != nil {
+		event.HTTPStatus = result.HTTPStatusCode
+		event.Environment = purchaseEnvironment(result.PurchaseType)
+	}
+	c.observe(event, start, err)
 	return result, err
 }
 
@@ -114,6 +134,33 @@ func (c *Client) CancelSubscription(packageName, subscriptionID, token string) e
 		return err
 	}
 
+	start := time.Now()
 	ps := androidpublisher.NewPurchasesSubscriptionsService(service)
-	return ps.Cancel(packageName, subscriptionID, token).Do()
+	err = ps.Cancel(packageName, subscriptionID, token).Do()
+
+	c.observe(observer.Event{Endpoint: "purchases.subscriptions.cancel"}, start, err)
+	return err
+}
+
+// observe sends the event of the API call to Observer
+func (c *Client) observe(event observer.Event, start time.Time, err error) {
+	if c.Observer == nil {
+		return
+	}
+	event.Store = observer.StorePlayStore
+	event.Latency = time.Since(start)
+	event.Err = err
+	if e, ok := err.(*googleapi.Error); ok {
+		event.HTTPStatus = e.Code
+		event.ErrorCode = e.Code
+	}
+	c.Observer.Observe(event)
+}
+
+// purchaseEnvironment returns the environment by purchaseType, which is 0 for the test purchase
+func purchaseEnvironment(purchaseType *int64) string {
+	if purchaseType != nil && *purchaseType == 0 {
+		return "Sandbox"
+	}
+	return "Production"
 }
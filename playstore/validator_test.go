@@ -5,6 +5,10 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/evalphobia/go-iap/observer"
 )
 
 type testSignature struct {
@@ -33,7 +37,7 @@ func TestSetTimeout(t *testing.T) {
 }
 
 func TestVerifySubscriptionAndroidPublisherError(t *testing.T) {
-	client := Client{nil}
+	client := Client{httpClient: nil}
 	expected := errors.New("client is nil")
 	_, actual := client.VerifySubscription("package", "subscriptionID", "purchaseToken")
 
@@ -43,7 +47,7 @@ func TestVerifySubscriptionAndroidPublisherError(t *testing.T) {
 }
 
 func TestVerifyProductAndroidPublisherError(t *testing.T) {
-	client := Client{nil}
+	client := Client{httpClient: nil}
 	expected := errors.New("client is nil")
 	_, actual := client.VerifyProduct("package", "productID", "purchaseToken")
 
@@ -51,3 +55,48 @@ func TestVerifyProductAndroidPublisherError(t *testing.T) {
 		t.Errorf("got %v\nwant %v", actual, expected)
 	}
 }
+
+func TestPurchaseEnvironment(t *testing.T) {
+	test, production := int64(0), int64(1)
+	tests := []struct {
+		purchaseType *int64
+		expected     string
+	}{
+		{nil, "Production"},
+		{&test, "Sandbox"},
+		{&production, "Production"},
+	}
+
+	for _, v := range tests {
+		actual := purchaseEnvironment(v.purchaseType)
+		if actual != v.expected {
+			t.Errorf("got %v\nwant %v", actual, v.expected)
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	var events []observer.Event
+	client := Client{Observer: observer.Func(func(e observer.Event) {
+		events = append(events, e)
+	})}
+
+	expected := &googleapi.Error{Code: 410, Message: "The subscription purchase is no longer available."}
+	client.observe(observer.Event{Endpoint: "purchases.subscriptions.get"}, time.Now(), expected)
+	client.observe(observer.Event{Endpoint: "purchases.products.get", HTTPStatus: 200}, time.Now(), nil)
+
+	if len(events) != 2 {
+		t.Fatalf("got %v\nwant %v", len(events), 2)
+	}
+	actual := events[0]
+	if actual.Store != observer.StorePlayStore || actual.Endpoint != "purchases.subscriptions.get" {
+		t.Errorf("got %#v\nwant %#v", actual.Endpoint, "purchases.subscriptions.get")
+	}
+	if actual.HTTPStatus != 410 || actual.ErrorCode != 410 || actual.Err != expected {
+		t.Errorf("got %#v\nwant %#v", actual.Err, expected)
+	}
+	actual = events[1]
+	if actual.HTTPStatus != 200 || actual.ErrorCode != 0 || actual.Err != nil {
+		t.Errorf("got %#v\nwant %#v", actual.HTTPStatus, 200)
+	}
+}